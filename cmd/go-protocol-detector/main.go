@@ -17,11 +17,30 @@ var (
 	thread   int
 	timeOut  int
 
-	user           string
-	password       string
-	priKeyFullPath string
-	csvOutput      string
-	noCSV          bool
+	user                string
+	password            string
+	priKeyFullPath      string
+	csvOutput           string
+	noCSV               bool
+	skipDiscovery       bool
+	proxy               string
+	sourceIP            string
+	iface               string
+	rdpFingerprint      bool
+	sshAuthMethods      bool
+	hostKeyBaseline     string
+	sftpAuthFallback    bool
+	sftpAudit           bool
+	sftpWriteTest       bool
+	passphrase          string
+	sshAgent            bool
+	keyboardInteractive bool
+	knownHosts          string
+	hostKeyCheck        string
+	ftpAnonymous        bool
+	sni                 string
+	tlsEnum             bool
+	smtpStartTLS        bool
 )
 
 var AppVersion = "unknow"
@@ -35,7 +54,7 @@ func main() {
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:        "protocol",
-				Usage:       "select only one protocol: rdp | ssh | ftp | sftp | telnet | vnc | common",
+				Usage:       "select only one protocol: rdp | ssh | ftp | ftps | sftp | telnet | vnc | http | tls | smtp | common",
 				Value:       "common",
 				Destination: &protocol,
 			},
@@ -46,7 +65,7 @@ func main() {
 			},
 			&cli.StringFlag{
 				Name:        "port",
-				Usage:       "support like: 22,80,443,3380-3390",
+				Usage:       "support like: 22,80,443,3380-3390, or named sets: default (protocol well-known ports) | top100 | remote-access",
 				Destination: &port,
 			},
 			&cli.IntFlag{
@@ -75,7 +94,7 @@ func main() {
 			},
 			&cli.StringFlag{
 				Name:        "prikey",
-				Usage:       "if you scan sftp, need give a pri key Full Path( user name or this priKeyFPath only chose one): ~/.ssh/id_rsa",
+				Usage:       "if you scan sftp, need give a pri key Full Path( user name or this priKeyFPath only chose one), several keys separated by ',' are tried in order, <key>-cert.pub is used as openssh certificate: ~/.ssh/id_rsa",
 				Value:       "~/.ssh/id_rsa",
 				Destination: &priKeyFullPath,
 			},
@@ -91,6 +110,120 @@ func main() {
 				Value:       false,
 				Destination: &noCSV,
 			},
+			&cli.BoolFlag{
+				Name:        "skip-discovery",
+				Usage:       "skip host discovery (TCP common ports / ICMP liveness check), scan every host directly",
+				Value:       false,
+				Destination: &skipDiscovery,
+			},
+			&cli.StringFlag{
+				Name:        "proxy",
+				Usage:       "scan through a proxy: socks5://[user:pass@]host:port | socks5h://... | http://[user:pass@]host:port",
+				Value:       "",
+				Destination: &proxy,
+			},
+			&cli.StringFlag{
+				Name:        "source-ip",
+				Usage:       "source ip address the probes originate from, e.g. 10.0.0.5",
+				Value:       "",
+				Destination: &sourceIP,
+			},
+			&cli.StringFlag{
+				Name:        "interface",
+				Usage:       "network interface the probes originate from (uses its first address), e.g. eth1",
+				Value:       "",
+				Destination: &iface,
+			},
+			&cli.BoolFlag{
+				Name:        "rdp-fingerprint",
+				Usage:       "rdp only: read the NTLM challenge over CredSSP (no credentials) to report windows build, computer name and domain",
+				Value:       false,
+				Destination: &rdpFingerprint,
+			},
+			&cli.BoolFlag{
+				Name:        "ssh-auth-methods",
+				Usage:       "ssh only: report the auth methods the server allows (publickey, password, keyboard-interactive, gssapi-with-mic) without sending credentials",
+				Value:       false,
+				Destination: &sshAuthMethods,
+			},
+			&cli.BoolFlag{
+				Name:        "ftp-anonymous",
+				Usage:       "ftp only: try to log in as anonymous and report whether anonymous access is allowed",
+				Value:       false,
+				Destination: &ftpAnonymous,
+			},
+			&cli.StringFlag{
+				Name:        "sni",
+				Usage:       "tls only: server name to send, compared with a handshake without SNI",
+				Value:       "",
+				Destination: &sni,
+			},
+			&cli.BoolFlag{
+				Name:        "smtp-starttls",
+				Usage:       "smtp only: upgrade with STARTTLS when advertised and record tls version, cipher, certificate and auth mechanisms",
+				Value:       false,
+				Destination: &smtpStartTLS,
+			},
+			&cli.BoolFlag{
+				Name:        "tls-enum",
+				Usage:       "tls only: enumerate accepted tls versions (ssl3 to tls1.3) and cipher suites and grade them weak/strong",
+				Value:       false,
+				Destination: &tlsEnum,
+			},
+			&cli.StringFlag{
+				Name:        "hostkey-baseline",
+				Usage:       "ssh/sftp only: json file of known host key fingerprints, flags hosts whose key changed and records new ones",
+				Value:       "",
+				Destination: &hostKeyBaseline,
+			},
+			&cli.BoolFlag{
+				Name:        "sftp-auth-fallback",
+				Usage:       "sftp only: when the server requires auth before the sftp subsystem can be confirmed, log in with --user and --password/--prikey",
+				Value:       false,
+				Destination: &sftpAuthFallback,
+			},
+			&cli.BoolFlag{
+				Name:        "sftp-audit",
+				Usage:       "sftp only: log in with --user and --password/--prikey to report sftp version, server extensions and chroot",
+				Value:       false,
+				Destination: &sftpAudit,
+			},
+			&cli.BoolFlag{
+				Name:        "sftp-write-test",
+				Usage:       "sftp only, with --sftp-audit: create and delete a test file in the login directory to check it is writable",
+				Value:       false,
+				Destination: &sftpWriteTest,
+			},
+			&cli.StringFlag{
+				Name:        "passphrase",
+				Usage:       "sftp only: passphrase of encrypted --prikey keys, default --password",
+				Value:       "",
+				Destination: &passphrase,
+			},
+			&cli.BoolFlag{
+				Name:        "ssh-agent",
+				Usage:       "sftp only: also try the keys held by the ssh agent at SSH_AUTH_SOCK",
+				Value:       false,
+				Destination: &sshAgent,
+			},
+			&cli.BoolFlag{
+				Name:        "keyboard-interactive",
+				Usage:       "sftp only: also try keyboard-interactive auth, answering every prompt with --password",
+				Value:       false,
+				Destination: &keyboardInteractive,
+			},
+			&cli.StringFlag{
+				Name:        "known-hosts",
+				Usage:       "sftp only: known_hosts file the host keys are verified against before logging in, default ~/.ssh/known_hosts",
+				Value:       "",
+				Destination: &knownHosts,
+			},
+			&cli.StringFlag{
+				Name:        "host-key-check",
+				Usage:       "sftp only: strict | accept-new | insecure, default insecure, or strict when --known-hosts is given",
+				Value:       "",
+				Destination: &hostKeyCheck,
+			},
 		},
 		Action: func(c *cli.Context) error {
 			// 检查是否没有任何参数被传递，如果没有则显示帮助信息
@@ -101,6 +234,70 @@ func main() {
 
 			nowProtocol := pkg.String2ProtocolType(protocol)
 			scanTools := pkg.NewScanTools(thread, time.Duration(timeOut)*time.Millisecond)
+			if !skipDiscovery {
+				scanTools.SetDiscovery(pkg.NewDefaultDiscoveryOptions())
+			}
+			scanTools.SetRDPFingerprint(rdpFingerprint)
+			scanTools.SetSSHAuthMethods(sshAuthMethods)
+			scanTools.SetFTPAnonymous(ftpAnonymous)
+			scanTools.SetTLSServerName(sni)
+			scanTools.SetTLSEnumerate(tlsEnum)
+			scanTools.SetSMTPStartTLS(smtpStartTLS)
+			scanTools.SetSFTPAuthFallback(sftpAuthFallback)
+			scanTools.SetSFTPAudit(sftpAudit, sftpWriteTest)
+			if sftpAuthFallback || sftpAudit {
+				// 默认的 root/root 和 ~/.ssh/id_rsa 只是示例，登录只使用显式指定的认证信息，
+				// 没有指定时不登录，SFTP 结果保持 auth_required
+				if !c.IsSet("user") {
+					user = ""
+				}
+				if !c.IsSet("password") {
+					password = ""
+				}
+				if !c.IsSet("prikey") {
+					priKeyFullPath = ""
+				}
+			}
+			// 多个私钥时第一个作为 InputInfo 的私钥，其余的依次尝试
+			var priKeys []string
+			for _, key := range strings.Split(priKeyFullPath, ",") {
+				if key = strings.TrimSpace(key); key != "" {
+					priKeys = append(priKeys, key)
+				}
+			}
+			priKeyFullPath = ""
+			if len(priKeys) > 0 {
+				priKeyFullPath, priKeys = priKeys[0], priKeys[1:]
+			}
+			scanTools.SetSSHAuth(pkg.SSHAuthOptions{
+				PrivateKeys:         priKeys,
+				Passphrase:          passphrase,
+				UseAgent:            sshAgent,
+				KeyboardInteractive: keyboardInteractive,
+			})
+			if hostKeyCheck == "" && knownHosts != "" {
+				hostKeyCheck = string(pkg.KnownHostsStrict)
+			}
+			if hostKeyCheck != "" {
+				if err := scanTools.SetKnownHosts(knownHosts, pkg.KnownHostsMode(hostKeyCheck)); err != nil {
+					return err
+				}
+			}
+			if hostKeyBaseline != "" {
+				if err := scanTools.SetHostKeyBaseline(hostKeyBaseline); err != nil {
+					return err
+				}
+			}
+			if sourceIP != "" || iface != "" {
+				if err := scanTools.SetSourceAddress(sourceIP, iface); err != nil {
+					return err
+				}
+			}
+			if proxy != "" {
+				if err := scanTools.SetProxy(proxy); err != nil {
+					return err
+				}
+			}
 
 			var outputInfo *pkg.OutputInfo
 			var err error
@@ -142,6 +339,9 @@ func main() {
 				for s2, i := range outputInfo.SuccessMapString {
 					info += s2 + ":" + strings.Join(i, ",") + "\r\n"
 				}
+				for target, metadata := range outputInfo.Metadata {
+					info += target + " " + pkg.FormatMetadata(metadata) + "\r\n"
+				}
 			}
 
 			if !noCSV {
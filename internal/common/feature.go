@@ -1,6 +1,23 @@
 package common
 
+import "time"
+
 type ReceiverFeature struct {
 	StartIndex   int
 	FeatureBytes []byte
 }
+
+// Timeouts 检测各阶段的超时时间
+type Timeouts struct {
+	Dial      time.Duration // 建立 TCP 连接
+	Read      time.Duration // 等待服务端响应
+	Handshake time.Duration // 协议握手，例如 SSH 握手
+}
+
+// Deadline 返回 timeout 之后的截止时间，timeout <= 0 表示不限制，返回零值清除连接的截止时间
+func Deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
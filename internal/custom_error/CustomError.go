@@ -10,9 +10,26 @@ var (
 	ErrVNCNotFound    = errors.New("vnc not found")
 	ErrSFTPNotFound   = errors.New("sftp not found")
 
+	// ErrSFTPAuthRequired 是 SSH 服务，但不认证无法确认是否支持 SFTP
+	ErrSFTPAuthRequired = errors.New("ssh found, sftp unknown without auth")
+
+	// ErrFTPSNotFound 不是 FTPS 服务，或者 FTP 服务拒绝了 AUTH TLS
+	ErrFTPSNotFound = errors.New("ftps not found")
+
+	// ErrHTTPNotFound 明文和 TLS 都没有收到 HTTP 响应
+	ErrHTTPNotFound = errors.New("http not found")
+
+	// ErrTLSNotFound TLS 握手失败
+	ErrTLSNotFound = errors.New("tls not found")
+
+	// ErrSMTPNotFound 没有 2xx/5xx 欢迎信息，或者 EHLO、HELO 都失败
+	ErrSMTPNotFound = errors.New("smtp not found")
+
 	ErrCommontPortCheckError = errors.New("commont port check error")
 
 	ErrInScanRangeCannotFound = errors.New("in scan range cannot found")
+
+	ErrHostNotAlive = errors.New("host not alive")
 )
 
 const ErrSSHOrgErrorInfo = "ssh: handshake failed"
@@ -0,0 +1,44 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// DialContextFunc 建立网络连接的函数，签名与 net.Dialer.DialContext 一致
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Direct 不经过任何代理直接建立连接
+func Direct(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
+
+// DialTimeout 使用 dial 在 timeout 内建立连接，dial 为 nil 时直接连接
+// timeout <= 0 表示不限制超时，与 net.DialTimeout 的语义一致
+func DialTimeout(dial DialContextFunc, network, address string, timeout time.Duration) (net.Conn, error) {
+	if dial == nil {
+		dial = Direct
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return dial(ctx, network, address)
+}
+
+// setDeadlineFromContext 在代理握手期间使用 ctx 的截止时间作为连接读写超时
+func setDeadlineFromContext(ctx context.Context, conn net.Conn) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		return conn.SetDeadline(deadline)
+	}
+	return nil
+}
+
+// clearDeadline 握手完成后清除超时，交给上层检测逻辑自行设置
+func clearDeadline(conn net.Conn) error {
+	return conn.SetDeadline(time.Time{})
+}
@@ -0,0 +1,89 @@
+package dialer
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// httpConnectDialer 通过 HTTP CONNECT 隧道建立 TCP 连接
+type httpConnectDialer struct {
+	proxyAddr string
+	username  string
+	password  string
+	useTLS    bool // 与代理之间使用 TLS（https://）
+	forward   DialContextFunc
+}
+
+func (h *httpConnectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := checkTCPNetwork(network); err != nil {
+		return nil, err
+	}
+
+	conn, err := h.forward(ctx, "tcp", h.proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("http proxy: dial proxy %s failed: %w", h.proxyAddr, err)
+	}
+	if h.useTLS {
+		proxyHost, _, _ := net.SplitHostPort(h.proxyAddr)
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyHost})
+	}
+	if err = setDeadlineFromContext(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tunnelConn, err := h.connect(conn, address)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err = clearDeadline(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tunnelConn, nil
+}
+
+// connect 发送 CONNECT 请求并校验代理应答
+func (h *httpConnectDialer) connect(conn net.Conn, address string) (net.Conn, error) {
+	request := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", address, address)
+	if h.username != "" {
+		credential := base64.StdEncoding.EncodeToString([]byte(h.username + ":" + h.password))
+		request += "Proxy-Authorization: Basic " + credential + "\r\n"
+	}
+	request += "\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return nil, fmt.Errorf("http proxy: write CONNECT request failed: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return nil, fmt.Errorf("http proxy: read CONNECT response failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http proxy: CONNECT %s failed: %s", address, resp.Status)
+	}
+
+	// 目标服务可能紧跟着应答发送数据（例如 SSH Banner），已被缓冲的部分需要先交给上层读取
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn 先从缓冲区读取数据的连接
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
@@ -0,0 +1,81 @@
+package dialer
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// NewProxyDialer 根据代理地址创建经过代理的拨号函数，forward 为连接代理服务器本身所用的拨号函数（nil 表示直连）
+// 支持的格式：
+//
+//	socks5://[user:pass@]host:port   目标域名在本地解析
+//	socks5h://[user:pass@]host:port  目标域名交给代理解析
+//	http://[user:pass@]host:port     HTTP CONNECT
+//	https://[user:pass@]host:port    基于 TLS 的 HTTP CONNECT
+func NewProxyDialer(proxyURL string, forward DialContextFunc) (DialContextFunc, error) {
+	if forward == nil {
+		forward = Direct
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url %q: %w", proxyURL, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q: missing host", proxyURL)
+	}
+
+	var username, password string
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	switch scheme {
+	case "socks5", "socks5h":
+		d := &socks5Dialer{
+			proxyAddr:     hostPortWithDefault(u, "1080"),
+			username:      username,
+			password:      password,
+			remoteResolve: scheme == "socks5h",
+			forward:       forward,
+		}
+		return d.DialContext, nil
+	case "http", "https":
+		defaultPort := "80"
+		if scheme == "https" {
+			defaultPort = "443"
+		}
+		d := &httpConnectDialer{
+			proxyAddr: hostPortWithDefault(u, defaultPort),
+			username:  username,
+			password:  password,
+			useTLS:    scheme == "https",
+			forward:   forward,
+		}
+		return d.DialContext, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (supported: socks5, socks5h, http, https)", u.Scheme)
+	}
+}
+
+// hostPortWithDefault 返回代理的 host:port，未指定端口时使用默认端口
+func hostPortWithDefault(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// checkTCPNetwork 代理只支持 TCP 连接
+func checkTCPNetwork(network string) error {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return nil
+	default:
+		return fmt.Errorf("proxy does not support network %q", network)
+	}
+}
@@ -0,0 +1,196 @@
+package dialer
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5AuthNone     = 0x00
+	socks5AuthPassword = 0x02
+	socks5AuthNoAccept = 0xff
+
+	socks5CmdConnect = 0x01
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+)
+
+// socks5ReplyMessages SOCKS5 CONNECT 应答码说明（RFC 1928）
+var socks5ReplyMessages = map[byte]string{
+	0x01: "general socks server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "ttl expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socks5Dialer 通过 SOCKS5 代理建立 TCP 连接（RFC 1928 / RFC 1929）
+type socks5Dialer struct {
+	proxyAddr     string
+	username      string
+	password      string
+	remoteResolve bool // 为 true 时由代理解析目标域名（socks5h）
+	forward       DialContextFunc
+}
+
+func (s *socks5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := checkTCPNetwork(network); err != nil {
+		return nil, err
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("socks5: invalid port %q", portStr)
+	}
+	if !s.remoteResolve && net.ParseIP(host) == nil {
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("socks5: resolve %s failed: %w", host, err)
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("socks5: no address found for %s", host)
+		}
+		host = ips[0].IP.String()
+	}
+
+	conn, err := s.forward(ctx, "tcp", s.proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("socks5: dial proxy %s failed: %w", s.proxyAddr, err)
+	}
+	if err = setDeadlineFromContext(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err = s.handshake(conn, host, port); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err = clearDeadline(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// handshake 完成认证方法协商、用户名密码认证以及 CONNECT 请求
+func (s *socks5Dialer) handshake(conn net.Conn, host string, port int) error {
+	methods := []byte{socks5AuthNone}
+	if s.username != "" {
+		methods = append(methods, socks5AuthPassword)
+	}
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return fmt.Errorf("socks5: write greeting failed: %w", err)
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("socks5: read method selection failed: %w", err)
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("socks5: unexpected protocol version %d", reply[0])
+	}
+	switch reply[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if err := s.authenticate(conn); err != nil {
+			return err
+		}
+	case socks5AuthNoAccept:
+		return fmt.Errorf("socks5: no acceptable authentication methods")
+	default:
+		return fmt.Errorf("socks5: unsupported authentication method %d", reply[1])
+	}
+
+	request := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			request = append(request, socks5AddrIPv4)
+			request = append(request, ip4...)
+		} else {
+			request = append(request, socks5AddrIPv6)
+			request = append(request, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("socks5: host name too long: %s", host)
+		}
+		request = append(request, socks5AddrDomain, byte(len(host)))
+		request = append(request, host...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("socks5: write connect request failed: %w", err)
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("socks5: read connect reply failed: %w", err)
+	}
+	if header[1] != 0x00 {
+		msg, ok := socks5ReplyMessages[header[1]]
+		if !ok {
+			msg = fmt.Sprintf("unknown reply code %d", header[1])
+		}
+		return fmt.Errorf("socks5: connect %s failed: %s", net.JoinHostPort(host, strconv.Itoa(port)), msg)
+	}
+
+	// 读取并丢弃代理返回的绑定地址
+	var addrLen int
+	switch header[3] {
+	case socks5AddrIPv4:
+		addrLen = net.IPv4len
+	case socks5AddrIPv6:
+		addrLen = net.IPv6len
+	case socks5AddrDomain:
+		lenBuf := make([]byte, 1)
+		if _, err := io.ReadFull(conn, lenBuf); err != nil {
+			return fmt.Errorf("socks5: read bind address failed: %w", err)
+		}
+		addrLen = int(lenBuf[0])
+	default:
+		return fmt.Errorf("socks5: unknown bind address type %d", header[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, addrLen+2)); err != nil {
+		return fmt.Errorf("socks5: read bind address failed: %w", err)
+	}
+	return nil
+}
+
+// authenticate 用户名密码认证（RFC 1929）
+func (s *socks5Dialer) authenticate(conn net.Conn) error {
+	if len(s.username) > 255 || len(s.password) > 255 {
+		return fmt.Errorf("socks5: username or password too long")
+	}
+	request := []byte{0x01, byte(len(s.username))}
+	request = append(request, s.username...)
+	request = append(request, byte(len(s.password)))
+	request = append(request, s.password...)
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("socks5: write authentication failed: %w", err)
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("socks5: read authentication reply failed: %w", err)
+	}
+	if reply[1] != 0x00 {
+		return fmt.Errorf("socks5: authentication rejected by proxy")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NewSourceDialer 创建从指定源地址发起连接的拨号函数
+// sourceIP 与 iface 都为空时直接返回 Direct；只指定 iface 时使用该网卡的第一个地址（优先 IPv4）；
+// 两者都指定时校验 sourceIP 确实属于该网卡
+func NewSourceDialer(sourceIP, iface string) (DialContextFunc, error) {
+	if sourceIP == "" && iface == "" {
+		return Direct, nil
+	}
+
+	var localIP net.IP
+	if sourceIP != "" {
+		localIP = net.ParseIP(sourceIP)
+		if localIP == nil {
+			return nil, fmt.Errorf("invalid source ip: %s", sourceIP)
+		}
+	}
+
+	if iface != "" {
+		ifaceIPs, err := interfaceIPs(iface)
+		if err != nil {
+			return nil, err
+		}
+		if localIP == nil {
+			localIP = pickInterfaceIP(ifaceIPs)
+			if localIP == nil {
+				return nil, fmt.Errorf("interface %s has no usable ip address", iface)
+			}
+		} else if !containsIP(ifaceIPs, localIP) {
+			return nil, fmt.Errorf("source ip %s does not belong to interface %s", localIP, iface)
+		}
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		d := net.Dialer{LocalAddr: localAddrFor(network, localIP)}
+		return d.DialContext(ctx, network, address)
+	}, nil
+}
+
+// localAddrFor 按照网络类型构造本地地址
+func localAddrFor(network string, ip net.IP) net.Addr {
+	switch {
+	case strings.HasPrefix(network, "tcp"):
+		return &net.TCPAddr{IP: ip}
+	case strings.HasPrefix(network, "udp"):
+		return &net.UDPAddr{IP: ip}
+	default:
+		// ip4:icmp 等原始 IP 网络
+		return &net.IPAddr{IP: ip}
+	}
+}
+
+// interfaceIPs 返回网卡上配置的所有 IP 地址
+func interfaceIPs(name string) ([]net.IP, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s not found: %w", name, err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses of interface %s: %w", name, err)
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		switch v := addr.(type) {
+		case *net.IPNet:
+			ips = append(ips, v.IP)
+		case *net.IPAddr:
+			ips = append(ips, v.IP)
+		}
+	}
+	return ips, nil
+}
+
+// pickInterfaceIP 优先选择 IPv4 地址，其次选择非链路本地的 IPv6 地址
+func pickInterfaceIP(ips []net.IP) net.IP {
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
+	}
+	for _, ip := range ips {
+		if !ip.IsLinkLocalUnicast() {
+			return ip
+		}
+	}
+	return nil
+}
+
+func containsIP(ips []net.IP, target net.IP) bool {
+	for _, ip := range ips {
+		if ip.Equal(target) {
+			return true
+		}
+	}
+	return false
+}
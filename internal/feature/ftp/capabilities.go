@@ -0,0 +1,204 @@
+package ftp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+)
+
+const maxReplyLines = 100
+
+var (
+	// ErrNotFTP 服务端没有返回 220 欢迎信息
+	ErrNotFTP = errors.New("server did not send an ftp greeting")
+	// ErrInvalidReply 服务端的响应不是 FTP 应答格式
+	ErrInvalidReply = errors.New("invalid ftp reply")
+)
+
+// Reply 一条 FTP 应答，多行应答（RFC 959 4.2）的每一行都在 Lines 中，不包含应答码
+type Reply struct {
+	Code  int
+	Lines []string
+}
+
+// Text 应答文本，多行时用 " | " 连接
+func (r Reply) Text() string {
+	return strings.Join(r.Lines, " | ")
+}
+
+// readReply 读取一条可能是多行的 FTP 应答：第一行为 "xyz-"，直到以 "xyz " 开头的行结束
+func readReply(r *bufio.Reader) (*Reply, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, ErrInvalidReply
+	}
+	code, err := strconv.Atoi(line[:3])
+	if err != nil || code < 100 || code > 599 {
+		return nil, ErrInvalidReply
+	}
+	reply := &Reply{Code: code, Lines: []string{strings.TrimSpace(line[3:])}}
+	if len(line) == 3 || line[3] != '-' {
+		return reply, nil
+	}
+
+	reply.Lines[0] = strings.TrimSpace(line[4:])
+	end := line[:3] + " "
+	for i := 0; i < maxReplyLines; i++ {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, end) || line == end[:3] {
+			reply.Lines = append(reply.Lines, strings.TrimSpace(line[3:]))
+			return reply, nil
+		}
+		// 中间行可能带应答码前缀 "xyz-"，也可能是任意文本
+		if strings.HasPrefix(line, end[:3]+"-") {
+			line = line[4:]
+		}
+		reply.Lines = append(reply.Lines, strings.TrimSpace(line))
+	}
+	return nil, ErrInvalidReply
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && !(err == io.EOF && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// command 发送一条命令并读取应答
+func command(conn io.Writer, r *bufio.Reader, format string, args ...interface{}) (*Reply, error) {
+	if _, err := fmt.Fprintf(conn, format+"\r\n", args...); err != nil {
+		return nil, err
+	}
+	return readReply(r)
+}
+
+// FTPInfo FTP 服务端的欢迎信息、系统类型和 FEAT 声明的功能
+type FTPInfo struct {
+	Greeting         []string `json:"greeting"`          // 220 欢迎信息，多行时每行一项
+	System           string   `json:"system"`            // SYST 返回的系统类型，例如 UNIX Type: L8
+	Features         []string `json:"features"`          // FEAT 返回的功能列表
+	UTF8             bool     `json:"utf8"`              // 是否声明 UTF8
+	MLST             bool     `json:"mlst"`              // 是否声明 MLST
+	EPSV             bool     `json:"epsv"`              // 是否声明 EPSV
+	AuthTLS          bool     `json:"auth_tls"`          // 是否声明 AUTH TLS
+	AnonymousTested  bool     `json:"anonymous_tested"`  // 是否尝试了匿名登录
+	AnonymousAllowed bool     `json:"anonymous_allowed"` // 是否允许匿名登录
+}
+
+// setFeatures 解析 FEAT 的功能列表，每行第一个单词是功能名称，后面是参数
+func (i *FTPInfo) setFeatures(features []string) {
+	for _, feature := range features {
+		if feature == "" {
+			continue
+		}
+		i.Features = append(i.Features, feature)
+		fields := strings.Fields(strings.ToUpper(feature))
+		switch fields[0] {
+		case "UTF8":
+			i.UTF8 = true
+		case "MLST":
+			i.MLST = true
+		case "EPSV":
+			i.EPSV = true
+		case "AUTH":
+			for _, mechanism := range fields[1:] {
+				for _, name := range strings.Split(mechanism, ";") {
+					if name == "TLS" {
+						i.AuthTLS = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// Capabilities 读取完整的 220 欢迎信息，发送 FEAT、SYST，tryAnonymous 为 true 时尝试匿名登录
+func (f FTPHelper) Capabilities(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc, tryAnonymous bool) (*FTPInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Read)); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	greeting, err := readReply(reader)
+	if err != nil {
+		return nil, err
+	}
+	if greeting.Code != 220 {
+		return nil, ErrNotFTP
+	}
+	info := &FTPInfo{Greeting: greeting.Lines, Features: make([]string, 0)}
+
+	// 收到 220 已经确认是 FTP，之后的命令失败只是缺少对应信息；不支持 FEAT、SYST 的服务端返回 500/502
+	if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		return info, nil
+	}
+	feat, err := command(conn, reader, "FEAT")
+	if err != nil {
+		return info, nil
+	}
+	if feat.Code == 211 && len(feat.Lines) > 2 {
+		// 第一行和最后一行是 "Features:"、"End"
+		info.setFeatures(feat.Lines[1 : len(feat.Lines)-1])
+	}
+	syst, err := command(conn, reader, "SYST")
+	if err != nil {
+		return info, nil
+	}
+	if syst.Code == 215 {
+		info.System = syst.Text()
+	}
+
+	if tryAnonymous {
+		info.AnonymousTested = true
+		user, err := command(conn, reader, "USER anonymous")
+		if err != nil {
+			return info, nil
+		}
+		if user.Code == 331 {
+			user, err = command(conn, reader, "PASS anonymous@example.com")
+			if err != nil {
+				return info, nil
+			}
+		}
+		info.AnonymousAllowed = user.Code == 230
+	}
+
+	command(conn, reader, "QUIT")
+	return info, nil
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i FTPInfo) Metadata() map[string]string {
+	metadata := map[string]string{
+		"ftp_banner":   strings.Join(i.Greeting, " | "),
+		"ftp_system":   i.System,
+		"ftp_features": strings.Join(i.Features, ","),
+		"ftp_utf8":     fmt.Sprintf("%v", i.UTF8),
+		"ftp_mlst":     fmt.Sprintf("%v", i.MLST),
+		"ftp_epsv":     fmt.Sprintf("%v", i.EPSV),
+		"ftp_auth_tls": fmt.Sprintf("%v", i.AuthTLS),
+	}
+	if i.AnonymousTested {
+		metadata["ftp_anonymous"] = fmt.Sprintf("%v", i.AnonymousAllowed)
+	}
+	return metadata
+}
@@ -28,3 +28,12 @@ func NewFTPHelper() *FTPHelper {
 func (f FTPHelper) GetVersion() string {
 	return f.version
 }
+
+// DefaultPorts 返回 FTP 协议的常用端口
+func DefaultPorts() []int {
+	return []int{21}
+}
+
+func (f FTPHelper) GetDefaultPorts() []int {
+	return DefaultPorts()
+}
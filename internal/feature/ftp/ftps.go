@@ -0,0 +1,135 @@
+package ftp
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	"github.com/allanpk716/go-protocol-detector/internal/utils"
+)
+
+const (
+	FTPSModeExplicit = "explicit" // 明文连接后通过 AUTH TLS 升级（RFC 4217）
+	FTPSModeImplicit = "implicit" // 连接建立后直接进行 TLS 握手，通常是 990 端口
+)
+
+// ErrAuthTLSRefused 服务端拒绝了 AUTH TLS，只支持明文 FTP
+var ErrAuthTLSRefused = errors.New("server refused auth tls")
+
+// FTPSInfo FTPS 协商结果
+type FTPSInfo struct {
+	Mode        string                 `json:"mode"`        // explicit 或 implicit
+	Greeting    []string               `json:"greeting"`    // 220 欢迎信息，explicit 模式是升级前的明文欢迎信息
+	TLSVersion  string                 `json:"tls_version"` // 协商的 TLS 版本，例如 TLS 1.2
+	CipherSuite string                 `json:"cipher"`      // 协商的加密套件
+	Certificate *utils.CertificateInfo `json:"certificate"` // 服务端证书
+}
+
+// newFTPSInfo 根据 TLS 握手结果生成 FTPS 协商结果
+func newFTPSInfo(mode string, greeting []string, tlsInfo *utils.TLSInfo) *FTPSInfo {
+	return &FTPSInfo{
+		Mode:        mode,
+		Greeting:    greeting,
+		TLSVersion:  tlsInfo.Version,
+		CipherSuite: tlsInfo.CipherSuite,
+		Certificate: tlsInfo.Leaf(),
+	}
+}
+
+// ExplicitTLS 读取 220 欢迎信息后发送 AUTH TLS，服务端返回 234 时完成 TLS 握手
+func (f FTPHelper) ExplicitTLS(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*FTPSInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Read)); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	greeting, err := readReply(reader)
+	if err != nil {
+		return nil, err
+	}
+	if greeting.Code != 220 {
+		return nil, ErrNotFTP
+	}
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		return nil, err
+	}
+	reply, err := command(conn, reader, "AUTH TLS")
+	if err != nil {
+		return nil, err
+	}
+	if reply.Code != 234 {
+		return nil, ErrAuthTLSRefused
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	tlsConn, tlsInfo, err := utils.TLSClient(conn, utils.InsecureTLSConfig(host))
+	if err != nil {
+		return nil, err
+	}
+	defer tlsConn.Close()
+	command(tlsConn, bufio.NewReader(tlsConn), "QUIT")
+	return newFTPSInfo(FTPSModeExplicit, greeting.Lines, tlsInfo), nil
+}
+
+// ImplicitTLS 连接建立后直接进行 TLS 握手，再在 TLS 之上读取 220 欢迎信息
+func (f FTPHelper) ImplicitTLS(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*FTPSInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	tlsConn, tlsInfo, err := utils.TLSClient(conn, utils.InsecureTLSConfig(host))
+	if err != nil {
+		return nil, err
+	}
+	defer tlsConn.Close()
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Read)); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(tlsConn)
+	greeting, err := readReply(reader)
+	if err != nil {
+		return nil, err
+	}
+	if greeting.Code != 220 {
+		return nil, ErrNotFTP
+	}
+	command(tlsConn, reader, "QUIT")
+	return newFTPSInfo(FTPSModeImplicit, greeting.Lines, tlsInfo), nil
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i FTPSInfo) Metadata() map[string]string {
+	metadata := map[string]string{
+		"ftp_banner":       strings.Join(i.Greeting, " | "),
+		"ftps_mode":        i.Mode,
+		"ftps_tls_version": i.TLSVersion,
+		"ftps_cipher":      i.CipherSuite,
+	}
+	if i.Certificate != nil {
+		for key, value := range i.Certificate.Metadata("ftps_") {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// FTPSDefaultPorts 返回 implicit FTPS 的常用端口
+func FTPSDefaultPorts() []int {
+	return []int{990}
+}
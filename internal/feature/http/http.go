@@ -0,0 +1,179 @@
+package http
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	nethttp "net/http"
+	"regexp"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	"github.com/allanpk716/go-protocol-detector/internal/utils"
+)
+
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+
+	// maxBodyLen 最多读取的响应内容，足够找到 <title> 和常见的 favicon
+	maxBodyLen = 256 * 1024
+	userAgent  = "Mozilla/5.0 (compatible; go-protocol-detector)"
+)
+
+// ErrEmptyFavicon 服务端没有返回 favicon
+var ErrEmptyFavicon = errors.New("empty favicon")
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+type HTTPHelper struct {
+	version string
+}
+
+func NewHTTPHelper() *HTTPHelper {
+	return &HTTPHelper{version: "v0.1"}
+}
+
+func (h HTTPHelper) GetVersion() string {
+	return h.version
+}
+
+// DefaultPorts 返回 HTTP/HTTPS 的常用端口，同一个端口会自动区分 HTTP 和 HTTPS
+func DefaultPorts() []int {
+	return []int{80, 443, 8000, 8008, 8080, 8081, 8443, 8888}
+}
+
+func (h HTTPHelper) GetDefaultPorts() []int {
+	return DefaultPorts()
+}
+
+// HTTPInfo GET / 的响应信息
+type HTTPInfo struct {
+	Scheme      string                 `json:"scheme"`       // http 或 https
+	StatusCode  int                    `json:"status_code"`  // 响应状态码
+	Server      string                 `json:"server"`       // Server 响应头
+	Title       string                 `json:"title"`        // HTML <title>
+	Location    string                 `json:"location"`     // 3xx 响应的跳转地址，不会跟随跳转
+	FaviconHash string                 `json:"favicon_hash"` // /favicon.ico 的 mmh3 哈希，与 Shodan 的 http.favicon.hash 相同
+	TLSVersion  string                 `json:"tls_version"`  // https 协商的 TLS 版本
+	Certificate *utils.CertificateInfo `json:"certificate"`  // https 的服务端证书
+}
+
+// Check 先尝试 TLS 握手判断是否为 HTTPS，失败时重新用明文连接，然后发送 GET / 并读取状态码、Server、标题和跳转地址
+// 先尝试 TLS 是因为不少 HTTPS 服务端收到明文请求时也会返回 400，直接用明文会误判为 HTTP
+func (h HTTPHelper) Check(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*HTTPInfo, error) {
+	info := &HTTPInfo{}
+	resp, body, tlsInfo, err := h.get(addr, "/", SchemeHTTPS, timeouts, dial)
+	if err == nil {
+		info.Scheme = SchemeHTTPS
+		info.TLSVersion = tlsInfo.Version
+		info.Certificate = tlsInfo.Leaf()
+	} else {
+		resp, body, _, err = h.get(addr, "/", SchemeHTTP, timeouts, dial)
+		if err != nil {
+			return nil, err
+		}
+		info.Scheme = SchemeHTTP
+	}
+
+	info.StatusCode = resp.StatusCode
+	info.Server = resp.Header.Get("Server")
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		info.Location = resp.Header.Get("Location")
+	}
+	if match := titlePattern.FindSubmatch(body); match != nil {
+		info.Title = strings.Join(strings.Fields(html.UnescapeString(string(match[1]))), " ")
+	}
+
+	// favicon 失败不影响检测结果
+	if hash, err := h.faviconHash(addr, info.Scheme, timeouts, dial); err == nil {
+		info.FaviconHash = hash
+	}
+	return info, nil
+}
+
+// faviconHash 读取 /favicon.ico 并计算 mmh3 哈希
+func (h HTTPHelper) faviconHash(addr, scheme string, timeouts common.Timeouts, dial dialer.DialContextFunc) (string, error) {
+	resp, body, _, err := h.get(addr, "/favicon.ico", scheme, timeouts, dial)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != nethttp.StatusOK || len(body) == 0 {
+		return "", ErrEmptyFavicon
+	}
+	return fmt.Sprintf("%d", FaviconHash(body)), nil
+}
+
+// get 建立新连接发送一次 GET 请求，读取响应和最多 maxBodyLen 的内容
+func (h HTTPHelper) get(addr, path, scheme string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*nethttp.Response, []byte, *utils.TLSInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer conn.Close()
+
+	var tlsInfo *utils.TLSInfo
+	if scheme == SchemeHTTPS {
+		if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+			return nil, nil, nil, err
+		}
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConn, info, err := utils.TLSClient(conn, utils.InsecureTLSConfig(host))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		tlsInfo = info
+		conn = tlsConn
+	}
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Read)); err != nil {
+		return nil, nil, nil, err
+	}
+	req, err := nethttp.NewRequest(nethttp.MethodGet, scheme+"://"+addr+path, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "*/*")
+	req.Close = true
+	if err := req.Write(conn); err != nil {
+		return nil, nil, nil, err
+	}
+	resp, err := nethttp.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer resp.Body.Close()
+	// 内容读取不完整（超时、连接被关闭）时保留已读取的部分
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodyLen))
+	return resp, body, tlsInfo, nil
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i HTTPInfo) Metadata() map[string]string {
+	metadata := map[string]string{
+		"http_scheme": i.Scheme,
+		"http_status": fmt.Sprintf("%d", i.StatusCode),
+		"http_server": i.Server,
+		"http_title":  i.Title,
+	}
+	if i.Location != "" {
+		metadata["http_location"] = i.Location
+	}
+	if i.FaviconHash != "" {
+		metadata["http_favicon_hash"] = i.FaviconHash
+	}
+	if i.TLSVersion != "" {
+		metadata["http_tls_version"] = i.TLSVersion
+	}
+	if i.Certificate != nil {
+		for key, value := range i.Certificate.Metadata("http_") {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,28 @@
+package rdp
+
+import (
+	"errors"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	"github.com/allanpk716/go-protocol-detector/internal/utils"
+)
+
+// ErrNoCertificate TLS 握手完成但服务端没有提供证书
+var ErrNoCertificate = errors.New("rdp server did not present a certificate")
+
+// Certificate 协商 TLS / CredSSP 后完成 TLS 握手，返回服务端证书信息
+// CredSSP 同样建立在 TLS 之上，所以只要服务端不是仅支持 Standard RDP 就能拿到证书
+func (r RDPHelper) Certificate(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*utils.CertificateInfo, error) {
+	conn, _, err := startTLS(addr, ProtocolSSL|ProtocolHybrid|ProtocolHybridEx, timeouts, dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	info := utils.PeerCertificateInfo(conn.ConnectionState())
+	if info == nil {
+		return nil, ErrNoCertificate
+	}
+	return info, nil
+}
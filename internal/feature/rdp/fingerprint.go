@@ -0,0 +1,133 @@
+package rdp
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	"github.com/allanpk716/go-protocol-detector/internal/utils"
+)
+
+var (
+	// ErrTLSNotSupported 服务端只支持 Standard RDP，无法建立 TLS
+	ErrTLSNotSupported = errors.New("rdp server does not support tls")
+	// ErrCredSSPNotSupported 服务端没有选择 CredSSP，无法通过 NTLM CHALLENGE 获取系统信息
+	ErrCredSSPNotSupported = errors.New("rdp server does not support credssp")
+)
+
+// OSInfo 通过 CredSSP 中 NTLM CHALLENGE 获取的目标系统信息
+type OSInfo struct {
+	NTLMChallengeInfo
+	Product string `json:"product"` // 根据 supportOSVersion 映射出的产品名称，未知时为空
+}
+
+// startTLS 完成 X.224 协商并在同一连接上建立 TLS，返回服务端选择的安全协议
+func startTLS(addr string, requestedProtocols uint32, timeouts common.Timeouts, dial dialer.DialContextFunc) (*tls.Conn, uint32, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := negotiateOnConn(conn, requestedProtocols, timeouts.Read)
+	if err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+	if resp.Failed || !resp.HasNegotiation || resp.SelectedProtocol == ProtocolRDP {
+		conn.Close()
+		return nil, 0, ErrTLSNotSupported
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	tlsConn := tls.Client(conn, utils.InsecureTLSConfig(host))
+	if err := tlsConn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		tlsConn.Close()
+		return nil, 0, err
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		tlsConn.Close()
+		return nil, 0, err
+	}
+	return tlsConn, resp.SelectedProtocol, nil
+}
+
+// Fingerprint 完成 TLS + CredSSP 交互直到服务端返回 NTLM CHALLENGE，
+// 从中获取 Windows 版本号、NetBIOS / DNS 计算机名和域名，整个过程不需要凭据
+func (r RDPHelper) Fingerprint(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*OSInfo, error) {
+	conn, selected, err := startTLS(addr, ProtocolSSL|ProtocolHybrid, timeouts, dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if selected&(ProtocolHybrid|ProtocolHybridEx) == 0 {
+		return nil, ErrCredSSPNotSupported
+	}
+
+	request, err := buildCredSSPNegotiate()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(common.Deadline(timeouts.Read)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	response, err := readDER(conn)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := parseCredSSPChallenge(response)
+	if err != nil {
+		return nil, err
+	}
+	ntlmInfo, err := ParseNTLMChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OSInfo{
+		NTLMChallengeInfo: *ntlmInfo,
+		Product:           r.ProductName(ntlmInfo.OSVersion),
+	}, nil
+}
+
+// ProductName 根据 major.minor.build 在 supportOSVersion 中查找产品名称，
+// NTLM 中不包含修订号，所以只比较前三段
+func (r RDPHelper) ProductName(osVersion string) string {
+	if osVersion == "" {
+		return ""
+	}
+
+	names := make([]string, 0)
+	for name, version := range r.supportOSVersion {
+		if version == osVersion || strings.HasPrefix(version, osVersion+".") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, "/")
+}
+
+// Metadata 转换为扫描结果的附加信息，只包含获取到的字段
+func (i OSInfo) Metadata() map[string]string {
+	metadata := make(map[string]string)
+	fields := map[string]string{
+		"rdp_os_version":       i.OSVersion,
+		"rdp_os_product":       i.Product,
+		"rdp_netbios_computer": i.NetBIOSComputer,
+		"rdp_netbios_domain":   i.NetBIOSDomain,
+		"rdp_dns_computer":     i.DNSComputer,
+		"rdp_dns_domain":       i.DNSDomain,
+	}
+	for key, value := range fields {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
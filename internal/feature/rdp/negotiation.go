@@ -0,0 +1,251 @@
+package rdp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+)
+
+// RDP 安全协议标志（MS-RDPBCGR 2.2.1.1.1 RDP_NEG_REQ requestedProtocols）
+const (
+	ProtocolRDP      uint32 = 0x00000000 // Standard RDP Security
+	ProtocolSSL      uint32 = 0x00000001 // TLS
+	ProtocolHybrid   uint32 = 0x00000002 // CredSSP (NLA)
+	ProtocolRDSTLS   uint32 = 0x00000004 // RDSTLS
+	ProtocolHybridEx uint32 = 0x00000008 // CredSSP with Early User Authorization Result PDU
+)
+
+const (
+	negTypeRequest  = 0x01
+	negTypeResponse = 0x02
+	negTypeFailure  = 0x03
+
+	x224ConnectionConfirm = 0xd0
+
+	maxTPKTLength = 4096
+)
+
+// failureCodeNames RDP_NEG_FAILURE 的 failureCode 含义
+var failureCodeNames = map[uint32]string{
+	0x01: "SSL_REQUIRED_BY_SERVER",
+	0x02: "SSL_NOT_ALLOWED_BY_SERVER",
+	0x03: "SSL_CERT_NOT_ON_SERVER",
+	0x04: "INCONSISTENT_FLAGS",
+	0x05: "HYBRID_REQUIRED_BY_SERVER",
+	0x06: "SSL_WITH_USER_AUTH_REQUIRED_BY_SERVER",
+}
+
+// ErrNotRDP 响应不是 X.224 Connection Confirm
+var ErrNotRDP = errors.New("response is not a x.224 connection confirm")
+
+// NegotiationResponse 服务端对 RDP_NEG_REQ 的应答
+type NegotiationResponse struct {
+	HasNegotiation   bool   // 是否携带 RDP_NEG_RSP / RDP_NEG_FAILURE，老版本服务端只返回 Connection Confirm
+	Failed           bool   // 是否为 RDP_NEG_FAILURE
+	Flags            byte   // RDP_NEG_RSP 的 flags
+	SelectedProtocol uint32 // 服务端选择的安全协议
+	FailureCode      uint32 // RDP_NEG_FAILURE 的失败码
+}
+
+// SecurityInfo 服务端支持的安全协议
+type SecurityInfo struct {
+	StandardRDP  bool              `json:"standard_rdp"`  // 传统 RDP 加密
+	TLS          bool              `json:"tls"`           // TLS
+	CredSSP      bool              `json:"credssp"`       // CredSSP (NLA)
+	CredSSPEx    bool              `json:"credssp_ex"`    // CredSSP + Early User Authorization
+	RDSTLS       bool              `json:"rdstls"`        // RDSTLS
+	NLAEnforced  bool              `json:"nla_enforced"`  // 是否强制 NLA（不接受 Standard RDP 和纯 TLS）
+	FailureCodes map[string]string `json:"failure_codes"` // 被拒绝的协议 -> 失败原因
+}
+
+// securityProbes 逐个探测的安全协议，顺序即报告顺序
+// requested 为请求的协议，protocol 为期望服务端选择的协议
+// MS-RDPBCGR 2.2.1.1.1 要求请求 PROTOCOL_HYBRID_EX 时同时设置 PROTOCOL_HYBRID
+var securityProbes = []struct {
+	name      string
+	requested uint32
+	protocol  uint32
+}{
+	{"RDP", ProtocolRDP, ProtocolRDP},
+	{"TLS", ProtocolSSL, ProtocolSSL},
+	{"CredSSP", ProtocolHybrid, ProtocolHybrid},
+	{"CredSSP-EX", ProtocolHybridEx | ProtocolHybrid, ProtocolHybridEx},
+	{"RDSTLS", ProtocolRDSTLS, ProtocolRDSTLS},
+}
+
+// BuildNegotiationRequest 构造携带 RDP_NEG_REQ 的 TPKT + X.224 Connection Request
+func BuildNegotiationRequest(requestedProtocols uint32) []byte {
+	packet := []byte{
+		0x03, 0x00, 0x00, 0x13, // TPKT，总长度 19
+		0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, // X.224 Connection Request
+		negTypeRequest, 0x00, 0x08, 0x00, // RDP_NEG_REQ type/flags/length
+		0x00, 0x00, 0x00, 0x00, // requestedProtocols
+	}
+	binary.LittleEndian.PutUint32(packet[15:], requestedProtocols)
+	return packet
+}
+
+// ReadTPKT 读取一个完整的 TPKT 报文
+func ReadTPKT(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 0x03 {
+		return nil, ErrNotRDP
+	}
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	if length < 4 || length > maxTPKTLength {
+		return nil, fmt.Errorf("invalid tpkt length %d", length)
+	}
+	packet := make([]byte, length)
+	copy(packet, header)
+	if _, err := io.ReadFull(r, packet[4:]); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// ParseNegotiationResponse 解析 X.224 Connection Confirm 以及其中的 RDP_NEG_RSP / RDP_NEG_FAILURE
+func ParseNegotiationResponse(packet []byte) (*NegotiationResponse, error) {
+	// TPKT(4) + X.224 CC 固定部分(7)
+	if len(packet) < 11 || packet[0] != 0x03 || packet[5]&0xf0 != x224ConnectionConfirm {
+		return nil, ErrNotRDP
+	}
+
+	resp := &NegotiationResponse{}
+	if len(packet) < 19 {
+		return resp, nil
+	}
+
+	neg := packet[11:19]
+	switch neg[0] {
+	case negTypeResponse:
+		resp.HasNegotiation = true
+		resp.Flags = neg[1]
+		resp.SelectedProtocol = binary.LittleEndian.Uint32(neg[4:8])
+	case negTypeFailure:
+		resp.HasNegotiation = true
+		resp.Failed = true
+		resp.FailureCode = binary.LittleEndian.Uint32(neg[4:8])
+	}
+	return resp, nil
+}
+
+// FailureCodeName 返回失败码的名称
+func FailureCodeName(code uint32) string {
+	if name, ok := failureCodeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN_FAILURE_0x%02x", code)
+}
+
+// Negotiate 建立新连接并发送一次协商请求
+func Negotiate(addr string, requestedProtocols uint32, timeouts common.Timeouts, dial dialer.DialContextFunc) (*NegotiationResponse, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return negotiateOnConn(conn, requestedProtocols, timeouts.Read)
+}
+
+func negotiateOnConn(conn net.Conn, requestedProtocols uint32, readTimeout time.Duration) (*NegotiationResponse, error) {
+	if err := conn.SetDeadline(common.Deadline(readTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(BuildNegotiationRequest(requestedProtocols)); err != nil {
+		return nil, err
+	}
+	packet, err := ReadTPKT(conn)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNegotiationResponse(packet)
+}
+
+// CheckSecurity 对每种安全协议单独发起协商，统计服务端支持的安全协议
+// 第一次探测使用与 SenderPackage 相同的 TLS|CredSSP 请求，用于确认是否为 RDP 服务
+func (r RDPHelper) CheckSecurity(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*SecurityInfo, error) {
+	if _, err := Negotiate(addr, ProtocolSSL|ProtocolHybrid, timeouts, dial); err != nil {
+		return nil, err
+	}
+
+	info := &SecurityInfo{
+		FailureCodes: make(map[string]string),
+	}
+	for _, probe := range securityProbes {
+		resp, err := Negotiate(addr, probe.requested, timeouts, dial)
+		if err != nil {
+			// 部分服务端收到不支持的协议会直接断开连接
+			info.FailureCodes[probe.name] = err.Error()
+			continue
+		}
+		if resp.Failed {
+			info.FailureCodes[probe.name] = FailureCodeName(resp.FailureCode)
+			continue
+		}
+
+		// 没有协商数据的老版本服务端只支持 Standard RDP
+		selected := ProtocolRDP
+		if resp.HasNegotiation {
+			selected = resp.SelectedProtocol
+		}
+		if selected != probe.protocol {
+			info.FailureCodes[probe.name] = fmt.Sprintf("server selected 0x%x", selected)
+			continue
+		}
+
+		switch probe.protocol {
+		case ProtocolRDP:
+			info.StandardRDP = true
+		case ProtocolSSL:
+			info.TLS = true
+		case ProtocolHybrid:
+			info.CredSSP = true
+		case ProtocolHybridEx:
+			info.CredSSPEx = true
+		case ProtocolRDSTLS:
+			info.RDSTLS = true
+		}
+	}
+	info.NLAEnforced = (info.CredSSP || info.CredSSPEx) && !info.StandardRDP && !info.TLS
+
+	return info, nil
+}
+
+// SupportedProtocols 返回支持的安全协议名称列表
+func (i SecurityInfo) SupportedProtocols() []string {
+	protocols := make([]string, 0)
+	if i.StandardRDP {
+		protocols = append(protocols, "RDP")
+	}
+	if i.TLS {
+		protocols = append(protocols, "TLS")
+	}
+	if i.CredSSP {
+		protocols = append(protocols, "CredSSP")
+	}
+	if i.CredSSPEx {
+		protocols = append(protocols, "CredSSP-EX")
+	}
+	if i.RDSTLS {
+		protocols = append(protocols, "RDSTLS")
+	}
+	return protocols
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i SecurityInfo) Metadata() map[string]string {
+	return map[string]string{
+		"rdp_security":     strings.Join(i.SupportedProtocols(), ","),
+		"rdp_nla_enforced": fmt.Sprintf("%v", i.NLAEnforced),
+	}
+}
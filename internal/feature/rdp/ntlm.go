@@ -0,0 +1,199 @@
+package rdp
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf16"
+)
+
+// ntlmChallengeMessage NTLMSSP CHALLENGE 消息类型
+const ntlmChallengeMessage = 2
+
+// NTLM AV_PAIR 类型（MS-NLMP 2.2.2.1）
+const (
+	avEOL             = 0x0000
+	avNbComputerName  = 0x0001
+	avNbDomainName    = 0x0002
+	avDNSComputerName = 0x0003
+	avDNSDomainName   = 0x0004
+	avDNSTreeName     = 0x0005
+	avTimestamp       = 0x0007
+)
+
+const ntlmNegotiateVersionFlag = 0x02000000
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ErrInvalidChallenge NTLM CHALLENGE 消息格式错误
+var ErrInvalidChallenge = errors.New("invalid ntlm challenge message")
+
+// ntlmNegotiate NTLM NEGOTIATE 消息，只用于让服务端返回 CHALLENGE，不包含任何凭据
+// flags 0xe20882b7：UNICODE | OEM | REQUEST_TARGET | SIGN | SEAL | NTLM | ALWAYS_SIGN | EXTENDED_SESSIONSECURITY |
+// TARGET_INFO | VERSION | 128 | KEY_EXCH | 56
+var ntlmNegotiate = []byte{
+	'N', 'T', 'L', 'M', 'S', 'S', 'P', 0x00,
+	0x01, 0x00, 0x00, 0x00, // MessageType
+	0xb7, 0x82, 0x08, 0xe2, // NegotiateFlags
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // DomainNameFields
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // WorkstationFields
+	0x0a, 0x00, 0x63, 0x45, 0x00, 0x00, 0x00, 0x0f, // Version 10.0.17763，NTLM revision 15
+}
+
+// NTLMChallengeInfo 从 NTLM CHALLENGE 消息中提取的服务端信息
+type NTLMChallengeInfo struct {
+	OSVersion       string    `json:"os_version"`       // major.minor.build，例如 10.0.17763
+	NetBIOSComputer string    `json:"netbios_computer"` // NetBIOS 计算机名
+	NetBIOSDomain   string    `json:"netbios_domain"`   // NetBIOS 域名
+	DNSComputer     string    `json:"dns_computer"`     // DNS 计算机名
+	DNSDomain       string    `json:"dns_domain"`       // DNS 域名
+	DNSTree         string    `json:"dns_tree"`         // DNS 林名
+	SystemTime      time.Time `json:"system_time"`      // 服务端时间
+}
+
+// ParseNTLMChallenge 解析 NTLM CHALLENGE 消息（MS-NLMP 2.2.1.2）
+func ParseNTLMChallenge(msg []byte) (*NTLMChallengeInfo, error) {
+	if len(msg) < 48 || !bytes.Equal(msg[:8], ntlmSignature) ||
+		binary.LittleEndian.Uint32(msg[8:12]) != ntlmChallengeMessage {
+		return nil, ErrInvalidChallenge
+	}
+
+	info := &NTLMChallengeInfo{}
+	flags := binary.LittleEndian.Uint32(msg[20:24])
+	if flags&ntlmNegotiateVersionFlag != 0 && len(msg) >= 56 {
+		info.OSVersion = fmt.Sprintf("%d.%d.%d", msg[48], msg[49], binary.LittleEndian.Uint16(msg[50:52]))
+	}
+
+	targetInfoLen := int(binary.LittleEndian.Uint16(msg[40:42]))
+	targetInfoOffset := int(binary.LittleEndian.Uint32(msg[44:48]))
+	if targetInfoLen == 0 {
+		return info, nil
+	}
+	if targetInfoOffset < 0 || targetInfoOffset+targetInfoLen > len(msg) {
+		return nil, ErrInvalidChallenge
+	}
+
+	targetInfo := msg[targetInfoOffset : targetInfoOffset+targetInfoLen]
+	for len(targetInfo) >= 4 {
+		avID := binary.LittleEndian.Uint16(targetInfo[0:2])
+		avLen := int(binary.LittleEndian.Uint16(targetInfo[2:4]))
+		if avID == avEOL {
+			break
+		}
+		if 4+avLen > len(targetInfo) {
+			return nil, ErrInvalidChallenge
+		}
+		value := targetInfo[4 : 4+avLen]
+		switch avID {
+		case avNbComputerName:
+			info.NetBIOSComputer = decodeUTF16LE(value)
+		case avNbDomainName:
+			info.NetBIOSDomain = decodeUTF16LE(value)
+		case avDNSComputerName:
+			info.DNSComputer = decodeUTF16LE(value)
+		case avDNSDomainName:
+			info.DNSDomain = decodeUTF16LE(value)
+		case avDNSTreeName:
+			info.DNSTree = decodeUTF16LE(value)
+		case avTimestamp:
+			if len(value) == 8 {
+				info.SystemTime = fileTimeToTime(binary.LittleEndian.Uint64(value))
+			}
+		}
+		targetInfo = targetInfo[4+avLen:]
+	}
+	return info, nil
+}
+
+func decodeUTF16LE(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// fileTimeToTime 将 Windows FILETIME（1601-01-01 起的 100ns 间隔）转换为 time.Time
+func fileTimeToTime(ft uint64) time.Time {
+	const epochDiff = 116444736000000000 // 1601-01-01 到 1970-01-01 的 100ns 间隔数
+	if ft < epochDiff {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ft-epochDiff)*100).UTC()
+}
+
+// tsRequest CredSSP TSRequest（MS-CSSP 2.2.1）
+type tsRequest struct {
+	Version     int         `asn1:"explicit,tag:0"`
+	NegoTokens  []negoToken `asn1:"optional,explicit,tag:1"`
+	AuthInfo    []byte      `asn1:"optional,explicit,tag:2"`
+	PubKeyAuth  []byte      `asn1:"optional,explicit,tag:3"`
+	ErrorCode   int         `asn1:"optional,explicit,tag:4"`
+	ClientNonce []byte      `asn1:"optional,explicit,tag:5"`
+}
+
+type negoToken struct {
+	Data []byte `asn1:"explicit,tag:0"`
+}
+
+// buildCredSSPNegotiate 构造携带 NTLM NEGOTIATE 的 TSRequest
+func buildCredSSPNegotiate() ([]byte, error) {
+	return asn1.Marshal(tsRequest{
+		Version:    2,
+		NegoTokens: []negoToken{{Data: ntlmNegotiate}},
+	})
+}
+
+// parseCredSSPChallenge 从服务端 TSRequest 中取出 NTLM CHALLENGE
+func parseCredSSPChallenge(data []byte) ([]byte, error) {
+	var req tsRequest
+	if _, err := asn1.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("parse TSRequest failed: %w", err)
+	}
+	if len(req.NegoTokens) == 0 {
+		if req.ErrorCode != 0 {
+			return nil, fmt.Errorf("credssp error code 0x%08x", uint32(req.ErrorCode))
+		}
+		return nil, errors.New("TSRequest has no negoTokens")
+	}
+	return req.NegoTokens[0].Data, nil
+}
+
+// readDER 读取一个完整的 DER 编码 TLV
+func readDER(r io.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := int(header[1])
+	lengthBytes := []byte{}
+	if header[1]&0x80 != 0 {
+		n := int(header[1] & 0x7f)
+		if n == 0 || n > 3 {
+			return nil, fmt.Errorf("unsupported DER length encoding 0x%02x", header[1])
+		}
+		lengthBytes = make([]byte, n)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, err
+		}
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxTPKTLength*4 {
+		return nil, fmt.Errorf("DER length %d too large", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	result := append(header, lengthBytes...)
+	return append(result, body...), nil
+}
@@ -22,13 +22,14 @@ func NewRDPHelper() *RDPHelper {
 		},
 		version: "v0.1",
 		supportOSVersion: map[string]string{
-			"2003":  "",
-			"Win7":  "",
-			"2008":  "",
-			"2012":  "",
-			"Win10": "10.0.18363.1256",
-			"2016":  "",
-			"2019":  "10.0.17763.1397",
+			"2003":   "5.2.3790",
+			"Win7":   "6.1.7601",
+			"2008R2": "6.1.7601",
+			"2008":   "6.0.6002",
+			"2012":   "6.2.9200",
+			"Win10":  "10.0.18363.1256",
+			"2016":   "10.0.14393",
+			"2019":   "10.0.17763.1397",
 		},
 	}
 
@@ -39,6 +40,15 @@ func (r RDPHelper) GetVersion() string {
 	return r.version
 }
 
+// DefaultPorts 返回 RDP 协议的常用端口
+func DefaultPorts() []int {
+	return []int{3389}
+}
+
+func (r RDPHelper) GetDefaultPorts() []int {
+	return DefaultPorts()
+}
+
 func (r RDPHelper) GetSupportOSVersion() map[string]string {
 	return r.supportOSVersion
 }
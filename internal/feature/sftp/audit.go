@@ -0,0 +1,268 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	sshfeature "github.com/allanpk716/go-protocol-detector/internal/feature/ssh"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshFxpVersion = 2
+
+	maxVersionPacket = 64 * 1024
+)
+
+// ErrCredentialsRequired 能力审计需要登录，没有提供用户名和密码/私钥/ssh-agent
+var ErrCredentialsRequired = errors.New("sftp audit requires user and password, private key or ssh agent")
+
+// systemDirs 正常文件系统根目录下一定存在的目录，一个都没有时认为用户被 chroot
+var systemDirs = []string{"bin", "etc", "usr"}
+
+// SFTPAuditInfo 认证登录后读取的 SFTP 服务端能力
+type SFTPAuditInfo struct {
+	ProtocolVersion uint32            `json:"protocol_version"` // 服务端 SSH_FXP_VERSION 中的协议版本
+	Extensions      []string          `json:"extensions"`       // 服务端声明的扩展，例如 posix-rename@openssh.com
+	ExtensionData   map[string]string `json:"extension_data"`   // 扩展名称对应的数据，通常是扩展的版本号
+	HomeDir         string            `json:"home_dir"`         // 登录后的工作目录
+	Chrooted        bool              `json:"chrooted"`         // 根目录下没有 bin、etc、usr，推断用户被限制在 chroot 中
+	WriteTested     bool              `json:"write_tested"`     // 是否进行了写入测试
+	Writable        bool              `json:"writable"`         // 能否在工作目录创建文件
+	WriteLeftover   string            `json:"write_leftover"`   // 写入测试的文件删除失败时留下的路径
+}
+
+// versionRecorder 记录服务端返回的第一个数据包，也就是 SSH_FXP_VERSION，之后的数据直接透传
+type versionRecorder struct {
+	reader io.Reader
+	mu     sync.Mutex
+	packet []byte
+	full   bool
+}
+
+func (r *versionRecorder) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		r.packet = append(r.packet, b[:n]...)
+		if len(r.packet) >= 4 && uint32(len(r.packet)-4) >= binary.BigEndian.Uint32(r.packet) || len(r.packet) > maxVersionPacket {
+			r.full = true
+		}
+	}
+	return n, err
+}
+
+func (r *versionRecorder) versionPacket() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.packet
+}
+
+// parseVersionPacket 解析 SSH_FXP_VERSION（draft-ietf-secsh-filexfer-02 4 节），返回协议版本和扩展
+func parseVersionPacket(packet []byte) (uint32, []string, map[string]string, error) {
+	if len(packet) < 9 || packet[4] != sshFxpVersion {
+		return 0, nil, nil, errors.New("server did not send sftp version")
+	}
+	length := binary.BigEndian.Uint32(packet)
+	if uint32(len(packet)-4) < length || length < 5 {
+		return 0, nil, nil, errors.New("sftp version packet truncated")
+	}
+	version := binary.BigEndian.Uint32(packet[5:])
+
+	names := make([]string, 0)
+	data := make(map[string]string)
+	rest := packet[9 : 4+length]
+	for len(rest) > 0 {
+		var name, value string
+		var ok bool
+		if name, rest, ok = readString(rest); !ok {
+			return version, names, data, errors.New("sftp version extension truncated")
+		}
+		if value, rest, ok = readString(rest); !ok {
+			return version, names, data, errors.New("sftp version extension truncated")
+		}
+		names = append(names, name)
+		data[name] = value
+	}
+	return version, names, data, nil
+}
+
+// readString 读取 SSH 编码的 string：uint32 长度 + 内容
+func readString(b []byte) (string, []byte, bool) {
+	if len(b) < 4 {
+		return "", b, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return "", b, false
+	}
+	return string(b[4 : 4+n]), b[4+n:], true
+}
+
+// sftpSession 认证后打开的 SFTP 会话
+type sftpSession struct {
+	sshClient     *ssh.Client
+	client        *sftp.Client
+	versionPacket []byte
+}
+
+// Close 先断开 SSH 连接再关闭 SFTP 客户端，服务端不关闭通道时 SFTP 客户端的 Close 也不会阻塞
+func (c *sftpSession) Close() {
+	c.sshClient.Close()
+	c.client.Close()
+}
+
+// openSession 登录并启动 SFTP 子系统，收到服务端的 SSH_FXP_VERSION 之前受握手超时限制
+func (s SFTPHelper) openSession(auth AuthOptions) (*sftpSession, error) {
+	authSession, err := auth.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	defer authSession.close()
+
+	netConn, err := dialer.DialTimeout(s.dial, "tcp", s.uri, s.timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:            auth.User,
+		Auth:            authSession.methods,
+		HostKeyCallback: s.hostKeys.Callback(&sshfeature.HostKeyInfo{}),
+	}
+
+	netConn.SetDeadline(common.Deadline(s.timeouts.Handshake))
+	sshCon, channels, requests, err := ssh.NewClientConn(netConn, s.uri, config)
+	if err != nil {
+		netConn.Close()
+		return nil, authSession.wrap(err)
+	}
+	sshClient := ssh.NewClient(sshCon, channels, requests)
+
+	sftpClient, recorder, err := newSFTPClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	// 握手完成后清除截止时间，SFTP 操作不受其限制
+	netConn.SetDeadline(time.Time{})
+	return &sftpSession{
+		sshClient:     sshClient,
+		client:        sftpClient,
+		versionPacket: recorder.versionPacket(),
+	}, nil
+}
+
+// newSFTPClient 与 sftp.NewClient 相同，但会记录服务端的 SSH_FXP_VERSION
+func newSFTPClient(sshClient *ssh.Client) (*sftp.Client, *versionRecorder, error) {
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return nil, nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	recorder := &versionRecorder{reader: stdout}
+	client, err := sftp.NewClientPipe(recorder, stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, recorder, nil
+}
+
+// Audit 登录后报告 SFTP 协议版本、服务端扩展、工作目录、是否被 chroot
+// writeTest 为 true 时在工作目录创建并删除一个测试文件，判断是否可写；会在目标上留下操作记录，默认不做
+func (s SFTPHelper) Audit(auth AuthOptions, writeTest bool) (*SFTPAuditInfo, error) {
+	if !auth.HasCredentials() {
+		return nil, ErrCredentialsRequired
+	}
+
+	session, err := s.openSession(auth)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	info := &SFTPAuditInfo{}
+	info.ProtocolVersion, info.Extensions, info.ExtensionData, err = parseVersionPacket(session.versionPacket)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.HomeDir, err = session.client.Getwd(); err != nil {
+		return nil, fmt.Errorf("读取工作目录失败: %w", err)
+	}
+	entries, err := session.client.ReadDir("/")
+	if err != nil {
+		return nil, fmt.Errorf("读取根目录失败: %w", err)
+	}
+	info.Chrooted = true
+	for _, entry := range entries {
+		for _, dir := range systemDirs {
+			if entry.Name() == dir {
+				info.Chrooted = false
+			}
+		}
+	}
+
+	if writeTest {
+		info.WriteTested = true
+		info.Writable, info.WriteLeftover = checkWritable(session.client, info.HomeDir)
+	}
+	return info, nil
+}
+
+// checkWritable 在 dir 下创建、写入并删除一个测试文件，删除失败时返回留下的文件路径
+func checkWritable(client *sftp.Client, dir string) (bool, string) {
+	name := path.Join(dir, fmt.Sprintf(".protocol-detector-%d", time.Now().UnixNano()))
+	file, err := client.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return false, ""
+	}
+	_, writeErr := file.Write([]byte("go-protocol-detector write test\n"))
+	file.Close()
+	if err := client.Remove(name); err != nil {
+		return writeErr == nil, name
+	}
+	return writeErr == nil, ""
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i SFTPAuditInfo) Metadata() map[string]string {
+	extensions := make([]string, 0, len(i.Extensions))
+	for _, name := range i.Extensions {
+		extensions = append(extensions, name+"="+i.ExtensionData[name])
+	}
+	metadata := map[string]string{
+		"sftp_version":    fmt.Sprintf("%d", i.ProtocolVersion),
+		"sftp_extensions": strings.Join(extensions, ","),
+		"sftp_home":       i.HomeDir,
+		"sftp_chrooted":   fmt.Sprintf("%v", i.Chrooted),
+	}
+	if i.WriteTested {
+		metadata["sftp_writable"] = fmt.Sprintf("%v", i.Writable)
+	}
+	if i.WriteLeftover != "" {
+		metadata["sftp_write_leftover"] = i.WriteLeftover
+	}
+	return metadata
+}
@@ -0,0 +1,174 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/allanpk716/go-protocol-detector/internal/utils"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AuthOptions 认证登录使用的凭据
+// 公钥认证依次尝试 PrivateKeys 中的私钥（存在 <私钥>-cert.pub 时先尝试 OpenSSH 证书）和 ssh-agent 中的密钥，
+// 之后是 password，最后是 keyboard-interactive
+type AuthOptions struct {
+	User                string   `json:"user"`
+	Password            string   `json:"-"`                    // 登录密码，同时用于回答 keyboard-interactive 的提问
+	PrivateKeys         []string `json:"private_keys"`         // 私钥文件，按顺序尝试
+	Passphrase          string   `json:"-"`                    // 私钥的密码，为空时加密的私钥使用 Password 解密
+	UseAgent            bool     `json:"use_agent"`            // 使用 ssh-agent 中的密钥
+	AgentSocket         string   `json:"agent_socket"`         // ssh-agent 的 unix socket，为空时使用 SSH_AUTH_SOCK
+	KeyboardInteractive bool     `json:"keyboard_interactive"` // 是否尝试 keyboard-interactive，所有提问都用 Password 回答
+}
+
+// NewAuthOptions 兼容原有的 user、password、priKeyFullPath 参数：指定私钥时 password 是私钥的密码，否则是登录密码
+func NewAuthOptions(user, password, priKeyFullPath string) AuthOptions {
+	if priKeyFullPath == "" {
+		return AuthOptions{User: user, Password: password}
+	}
+	return AuthOptions{User: user, PrivateKeys: []string{priKeyFullPath}, Passphrase: password}
+}
+
+// HasCredentials 是否提供了用户名以及至少一种认证方式
+func (o AuthOptions) HasCredentials() bool {
+	return o.User != "" && (o.Password != "" || len(o.PrivateKeys) > 0 || o.UseAgent)
+}
+
+// authSession 一次登录使用的认证方法，登录结束后调用 close 关闭 ssh-agent 连接
+type authSession struct {
+	methods   []ssh.AuthMethod
+	agentConn net.Conn
+	agentErr  error // 从 ssh-agent 读取密钥失败的错误，用于区分 agent 不可用和 agent 中没有密钥
+}
+
+func (a *authSession) close() {
+	if a.agentConn != nil {
+		a.agentConn.Close()
+	}
+}
+
+// wrap 登录失败时附加 ssh-agent 的错误
+func (a *authSession) wrap(err error) error {
+	if err == nil || a.agentErr == nil {
+		return err
+	}
+	return fmt.Errorf("%w (ssh agent: %v)", err, a.agentErr)
+}
+
+// authMethods 生成 x/crypto/ssh 的认证方法
+// x/crypto/ssh 同一种认证方法只会尝试一次，所以所有公钥放在同一个 PublicKeysCallback 中
+// 私钥和密码是两种独立的认证方式：读取不了的私钥会被跳过，只有没有其它认证方式时才返回私钥的错误
+func (o AuthOptions) authMethods() (*authSession, error) {
+	passphrase := o.Passphrase
+	if passphrase == "" {
+		passphrase = o.Password
+	}
+	signers := make([]ssh.Signer, 0, len(o.PrivateKeys))
+	var keyErr error
+	for _, keyPath := range o.PrivateKeys {
+		keySigners, err := loadSigners(keyPath, passphrase)
+		if err != nil {
+			if keyErr == nil {
+				keyErr = err
+			}
+			continue
+		}
+		signers = append(signers, keySigners...)
+	}
+
+	session := &authSession{}
+	if o.UseAgent {
+		socket := o.AgentSocket
+		if socket == "" {
+			socket = os.Getenv("SSH_AUTH_SOCK")
+		}
+		if socket == "" {
+			return nil, fmt.Errorf("ssh agent requested but SSH_AUTH_SOCK is not set")
+		}
+		var err error
+		session.agentConn, err = net.Dial("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to ssh agent: %w", err)
+		}
+	}
+
+	methods := make([]ssh.AuthMethod, 0, 3)
+	if len(signers) > 0 || session.agentConn != nil {
+		methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			if session.agentConn == nil {
+				return signers, nil
+			}
+			agentSigners, err := agent.NewClient(session.agentConn).Signers()
+			if err != nil {
+				// 私钥文件仍然可以尝试，登录失败时错误信息中会带上 agent 的错误
+				session.agentErr = fmt.Errorf("failed to list ssh agent keys: %w", err)
+				return signers, nil
+			}
+			return append(append([]ssh.Signer{}, signers...), agentSigners...), nil
+		}))
+	}
+	if o.Password != "" {
+		methods = append(methods, ssh.Password(o.Password))
+		if o.KeyboardInteractive {
+			methods = append(methods, ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = o.Password
+				}
+				return answers, nil
+			}))
+		}
+	}
+
+	if len(methods) == 0 && keyErr != nil {
+		return nil, keyErr
+	}
+	session.methods = methods
+	return session, nil
+}
+
+// loadSigners 读取私钥，存在同名的 -cert.pub 证书时证书在前，只有私钥是加密的才使用 passphrase
+func loadSigners(keyPath, passphrase string) ([]ssh.Signer, error) {
+	// 验证私钥文件路径是否安全
+	if err := utils.ValidatePrivateKeyPath(keyPath); err != nil {
+		return nil, fmt.Errorf("invalid private key path: %w", err)
+	}
+
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file %s: %w", filepath.Base(keyPath), err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(keyData)
+	var missingErr *ssh.PassphraseMissingError
+	if errors.As(err, &missingErr) && passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key %s with passphrase: %w", filepath.Base(keyPath), err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", filepath.Base(keyPath), err)
+	}
+
+	certData, err := os.ReadFile(keyPath + "-cert.pub")
+	if err != nil {
+		return []ssh.Signer{signer}, nil
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s-cert.pub: %w", filepath.Base(keyPath), err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s-cert.pub is not an openssh certificate", filepath.Base(keyPath))
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate %s-cert.pub does not match private key: %w", filepath.Base(keyPath), err)
+	}
+	return []ssh.Signer{certSigner, signer}, nil
+}
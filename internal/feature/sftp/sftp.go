@@ -1,50 +1,97 @@
 package sftp
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"net"
-	"bufio"
+	"strings"
+	"time"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
 	"github.com/allanpk716/go-protocol-detector/internal/custom_error"
-	"github.com/allanpk716/go-protocol-detector/internal/utils"
-	"github.com/pkg/sftp"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	sshfeature "github.com/allanpk716/go-protocol-detector/internal/feature/ssh"
 	"golang.org/x/crypto/ssh"
-	"os"
-	"path/filepath"
-	"time"
-	"strings"
+)
+
+// SFTPStatus SFTP 检测的三值结果
+type SFTPStatus string
+
+const (
+	SFTPStatusConfirmed SFTPStatus = "confirmed"     // SFTP 子系统已确认可用
+	SFTPStatusUnknown   SFTPStatus = "auth_required" // 是 SSH 服务，但不认证无法确认是否支持 SFTP
+	SFTPStatusNotFound  SFTPStatus = "not_found"     // 不是 SSH 服务，或者服务端拒绝了 SFTP 子系统
 )
 
 type SFTPHelper struct {
-	uri     string
-	timeout time.Duration
+	uri      string
+	timeouts common.Timeouts
+	dial     dialer.DialContextFunc
+	hostKeys *sshfeature.HostKeyVerifier // 主机密钥校验，nil 表示不校验
 }
 
 // SFTPDiagnostics 包含SFTP检测的详细诊断信息
 type SFTPDiagnostics struct {
-	TCPConnected       bool          `json:"tcp_connected"`       // TCP连接是否成功
-	SSHBanner          string        `json:"ssh_banner"`          // SSH服务器banner信息
-	SSHVersion         string        `json:"ssh_version"`         // SSH版本信息
-	SFTPSupported      bool          `json:"sftp_supported"`      // SFTP subsystem是否支持
-	SubsystemResponse  string        `json:"subsystem_response"`  // subsystem响应信息
-	ElapsedTime        int64         `json:"elapsed_time_ms"`     // 检测耗时（毫秒）
-	ErrorMsg           string        `json:"error_msg"`           // 错误信息
+	Status             SFTPStatus `json:"status"`               // 三值检测结果
+	TCPConnected       bool       `json:"tcp_connected"`        // TCP连接是否成功
+	SSHBanner          string     `json:"ssh_banner"`           // SSH服务器banner信息
+	SSHVersion         string     `json:"ssh_version"`          // SSH协议版本
+	SSHSoftware        string     `json:"ssh_software"`         // SSH软件名称，例如 OpenSSH
+	SSHSoftwareVersion string     `json:"ssh_software_version"` // SSH软件版本，例如 8.9p1
+	SSHComments        string     `json:"ssh_comments"`         // banner注释，例如 Ubuntu-3ubuntu0.6
+	SSHOS              string     `json:"ssh_os"`               // 根据banner推断的操作系统
+	SFTPSupported      bool       `json:"sftp_supported"`       // SFTP subsystem是否支持
+	SubsystemResponse  string     `json:"subsystem_response"`   // subsystem响应信息
+	HostKeyType        string     `json:"host_key_type"`        // 主机密钥类型
+	HostKeySHA256      string     `json:"host_key_sha256"`      // 主机密钥 SHA256 指纹
+	HostKeyError       string     `json:"host_key_error"`       // 登录前主机密钥校验失败的原因，没有发送认证信息
+	ElapsedTime        int64      `json:"elapsed_time_ms"`      // 检测耗时（毫秒）
+	ErrorMsg           string     `json:"error_msg"`            // 错误信息
 }
 
-func NewSFTPHelper(host, port string, timeout time.Duration) *SFTPHelper {
+func NewSFTPHelper(host, port string, timeouts common.Timeouts, dial dialer.DialContextFunc) *SFTPHelper {
 	uri := net.JoinHostPort(host, port)
 	sftpHelper := SFTPHelper{
-		uri:     uri,
-		timeout: timeout,
+		uri:      uri,
+		timeouts: timeouts,
+		dial:     dial,
 	}
 	return &sftpHelper
 }
 
+// SetHostKeyVerifier 设置登录时的主机密钥校验，nil 表示不校验；不认证的协议检测只记录主机密钥
+func (s *SFTPHelper) SetHostKeyVerifier(verifier *sshfeature.HostKeyVerifier) {
+	s.hostKeys = verifier
+}
+
+// Check SFTP协议检测：不使用认证信息，专注于协议识别
+// SFTP 已确认返回 nil；是 SSH 服务但需要认证才能确认时返回 ErrSFTPAuthRequired；否则返回 ErrSFTPNotFound
 func (s SFTPHelper) Check(user, password, priKeyFullPath string) error {
-	// SFTP协议检测：不使用认证信息，专注于协议识别
 	_, err := s.checkSFTPProtocolWithDiagnostics()
 	return err
 }
 
+// CheckWithFallback 先做无认证检测，结果为 SFTPStatusUnknown 且提供了认证信息时，再登录确认
+func (s SFTPHelper) CheckWithFallback(auth AuthOptions) (*SFTPDiagnostics, error) {
+	diagnostics, err := s.checkSFTPProtocolWithDiagnostics()
+	if diagnostics.Status != SFTPStatusUnknown || !auth.HasCredentials() {
+		return diagnostics, err
+	}
+
+	if authErr := s.loginCheck(auth); authErr != nil {
+		diagnostics.RecordHostKeyError(authErr)
+		diagnostics.ErrorMsg = fmt.Sprintf("认证后确认SFTP失败: %v", authErr)
+		return diagnostics, err
+	}
+	diagnostics.Status = SFTPStatusConfirmed
+	diagnostics.SFTPSupported = true
+	diagnostics.SubsystemResponse = "认证后确认SFTP子系统可用"
+	diagnostics.ErrorMsg = ""
+	return diagnostics, nil
+}
+
 // CheckWithDiagnostics 执行SFTP检测并返回详细的诊断信息
 func (s SFTPHelper) CheckWithDiagnostics() (*SFTPDiagnostics, error) {
 	return s.checkSFTPProtocolWithDiagnostics()
@@ -52,55 +99,31 @@ func (s SFTPHelper) CheckWithDiagnostics() (*SFTPDiagnostics, error) {
 
 // 保留认证检测方法作为备用（仅在用户提供认证信息时使用）
 func (s SFTPHelper) CheckWithAuth(user, password, priKeyFullPath string) error {
-	if user == "" || (password == "" && priKeyFullPath == "") {
+	return s.CheckWithAuthOptions(NewAuthOptions(user, password, priKeyFullPath))
+}
+
+// CheckWithAuthOptions 使用 ssh-agent、多个私钥、证书、密码或 keyboard-interactive 登录后确认 SFTP 可用
+func (s SFTPHelper) CheckWithAuthOptions(auth AuthOptions) error {
+	if !auth.HasCredentials() {
 		// 如果没有提供认证信息，使用协议检测方式
 		_, err := s.checkSFTPProtocolWithDiagnostics()
 		return err
 	}
 
 	// 用户提供了认证信息，使用传统的认证检测方式
-	var authMethod ssh.AuthMethod
-	if priKeyFullPath == "" {
-		authMethod = ssh.Password(password)
-	} else {
-		// 验证私钥文件路径是否安全
-		if err := utils.ValidatePrivateKeyPath(priKeyFullPath); err != nil {
-			return fmt.Errorf("invalid private key path: %w", err)
-		}
-
-		// use private key login, password belong to private key
-		keyData, err := os.ReadFile(priKeyFullPath)
-		if err != nil {
-			return fmt.Errorf("failed to read private key file %s: %w", filepath.Base(priKeyFullPath), err)
-		}
-
-		var signer ssh.Signer
-		if password != "" {
-			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(password))
-			if err != nil {
-				return fmt.Errorf("failed to parse private key with passphrase: %w", err)
-			}
-		} else {
-			signer, err = ssh.ParsePrivateKey(keyData)
-			if err != nil {
-				return fmt.Errorf("failed to parse private key: %w", err)
-			}
-		}
-		authMethod = ssh.PublicKeys(signer)
-	}
-
-	return s.checkWithAuth(user, authMethod)
+	return s.checkWithAuth(auth)
 }
 
 // 带诊断信息的SFTP协议检测方法 - 简化为3层检测
 func (s SFTPHelper) checkSFTPProtocolWithDiagnostics() (*SFTPDiagnostics, error) {
 	startTime := time.Now()
 	diagnostics := &SFTPDiagnostics{
+		Status:       SFTPStatusNotFound,
 		TCPConnected: false,
 	}
 
 	// Layer 1: TCP连接测试
-	netConn, err := net.DialTimeout("tcp", s.uri, s.timeout)
+	netConn, err := dialer.DialTimeout(s.dial, "tcp", s.uri, s.timeouts.Dial)
 	if err != nil {
 		diagnostics.ErrorMsg = fmt.Sprintf("TCP连接失败: %v", err)
 		diagnostics.ElapsedTime = time.Since(startTime).Milliseconds()
@@ -110,7 +133,7 @@ func (s SFTPHelper) checkSFTPProtocolWithDiagnostics() (*SFTPDiagnostics, error)
 	diagnostics.TCPConnected = true
 
 	// Layer 2: SSH协议识别 - 读取SSH Banner
-	netConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	netConn.SetReadDeadline(common.Deadline(s.timeouts.Read))
 	reader := bufio.NewReader(netConn)
 	banner, err := reader.ReadString('\n')
 	if err != nil {
@@ -126,54 +149,76 @@ func (s SFTPHelper) checkSFTPProtocolWithDiagnostics() (*SFTPDiagnostics, error)
 		return diagnostics, custom_error.ErrSFTPNotFound
 	}
 
-	// 提取SSH版本信息
-	parts := strings.Split(diagnostics.SSHBanner, "-")
-	if len(parts) >= 2 {
-		diagnostics.SSHVersion = parts[1]
+	// 解析SSH版本标识
+	if bannerInfo, err := sshfeature.ParseBanner(diagnostics.SSHBanner); err == nil {
+		diagnostics.SSHVersion = bannerInfo.ProtocolVersion
+		diagnostics.SSHSoftware = bannerInfo.Software
+		diagnostics.SSHSoftwareVersion = bannerInfo.SoftwareVersion
+		diagnostics.SSHComments = bannerInfo.Comments
+		diagnostics.SSHOS = bannerInfo.OS
 	}
 
-	// Layer 3: SFTP子系统支持检测
-	sftpSupported, subsystemResponse, err := s.detectSFTPSupport(netConn)
+	// 已经确认是 SSH 服务，之后只剩下是否支持 SFTP 的问题
+	diagnostics.Status = SFTPStatusUnknown
+
+	// Layer 3: SFTP子系统支持检测，在同一个连接上回放已读取的 banner 后完成 SSH 握手
+	replayConn := &replayConn{Conn: netConn, reader: io.MultiReader(strings.NewReader(banner), reader)}
+	hostKey := &sshfeature.HostKeyInfo{}
+	status, subsystemResponse, err := s.detectSFTPSupport(replayConn, hostKey)
+	diagnostics.HostKeyType = hostKey.Type
+	diagnostics.HostKeySHA256 = hostKey.SHA256
+	diagnostics.SubsystemResponse = subsystemResponse
+	diagnostics.ElapsedTime = time.Since(startTime).Milliseconds()
+	diagnostics.Status = status
 	if err != nil {
 		diagnostics.ErrorMsg = fmt.Sprintf("SFTP子系统检测失败: %v", err)
-		diagnostics.ElapsedTime = time.Since(startTime).Milliseconds()
 		return diagnostics, err
 	}
 
-	diagnostics.SFTPSupported = sftpSupported
-	diagnostics.SubsystemResponse = subsystemResponse
-	diagnostics.ElapsedTime = time.Since(startTime).Milliseconds()
-
-	if !sftpSupported {
+	switch status {
+	case SFTPStatusConfirmed:
+		diagnostics.SFTPSupported = true
+		return diagnostics, nil
+	case SFTPStatusUnknown:
+		return diagnostics, custom_error.ErrSFTPAuthRequired
+	default:
 		return diagnostics, custom_error.ErrSFTPNotFound
 	}
+}
 
-	return diagnostics, nil
+// replayConn 先返回已经从连接上读出的数据，再继续读取连接
+type replayConn struct {
+	net.Conn
+	reader io.Reader
 }
 
-// detectSFTPSupport 检测SSH服务是否支持SFTP子系统
-func (s SFTPHelper) detectSFTPSupport(netConn net.Conn) (bool, string, error) {
-	// 为SSH连接建立新的连接（复用现有连接可能导致状态混乱）
-	sshConn, err := net.DialTimeout("tcp", s.uri, s.timeout/2)
-	if err != nil {
-		return false, "", fmt.Errorf("建立SSH连接失败: %w", err)
-	}
-	defer sshConn.Close()
+func (c *replayConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// detectSFTPSupport 检测SSH服务是否支持SFTP子系统，密钥交换时把服务端主机密钥记录到 hostKey
+// 这里不发送认证信息，只记录主机密钥而不按照 known_hosts 校验，校验在登录时进行
+// 服务端拒绝 "none" 认证时返回 SFTPStatusUnknown；密钥交换失败、超时、连接被重置等握手错误返回 SFTPStatusNotFound 和实际的错误
+func (s SFTPHelper) detectSFTPSupport(sshConn net.Conn, hostKey *sshfeature.HostKeyInfo) (SFTPStatus, string, error) {
+	// ssh.ClientConfig.Timeout 只作用于 ssh.Dial，这里通过连接的截止时间限制整个握手和子系统探测过程
+	sshConn.SetDeadline(common.Deadline(s.timeouts.Handshake))
 
 	// 配置SSH客户端 - 使用协议检测专用的用户名和空认证
 	config := &ssh.ClientConfig{
 		User:            "protocol-detector", // 专用于协议检测的用户名
-		Auth:            []ssh.AuthMethod{},   // 空认证数组
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         s.timeout / 2,
+		Auth:            []ssh.AuthMethod{},  // 空认证数组
+		HostKeyCallback: (*sshfeature.HostKeyVerifier)(nil).Callback(hostKey),
 		ClientVersion:   "SSH-2.0-ProtocolDetector",
 	}
 
 	// 建立SSH客户端连接
 	sshClientConn, _, requests, err := ssh.NewClientConn(sshConn, s.uri, config)
+	if err != nil && isAuthRejected(err) {
+		// 绝大多数服务端都会拒绝 "none" 认证，不认证无法确认是否支持 SFTP
+		return SFTPStatusUnknown, "SSH连接需要认证", nil
+	}
 	if err != nil {
-		// 如果连接失败，说明SSH服务需要认证，但这不影响SFTP支持检测
-		return false, "SSH连接需要认证", nil
+		return SFTPStatusNotFound, "SSH握手失败", fmt.Errorf("SSH握手失败: %w", err)
 	}
 	defer sshClientConn.Close()
 
@@ -183,61 +228,91 @@ func (s SFTPHelper) detectSFTPSupport(netConn net.Conn) (bool, string, error) {
 	// 尝试打开session通道
 	channel, _, err := sshClientConn.OpenChannel("session", nil)
 	if err != nil {
-		return false, "", fmt.Errorf("打开session通道失败: %w", err)
+		return SFTPStatusUnknown, "", fmt.Errorf("打开session通道失败: %w", err)
 	}
 	defer channel.Close()
 
 	// 发送subsystem请求尝试启动SFTP
 	ok, err := channel.SendRequest("subsystem", true, ssh.Marshal(&struct{ Name string }{"sftp"}))
 	if err != nil {
-		return false, "", fmt.Errorf("发送subsystem请求失败: %w", err)
+		return SFTPStatusUnknown, "", fmt.Errorf("发送subsystem请求失败: %w", err)
 	}
 
 	if ok {
-		return true, "SFTP子系统支持", nil
+		return SFTPStatusConfirmed, "SFTP子系统支持", nil
 	} else {
-		return false, "SFTP子系统不支持", nil
+		return SFTPStatusNotFound, "SFTP子系统不支持", nil
 	}
 }
 
+// isAuthRejected 密钥交换已经完成，服务端拒绝了认证
+func isAuthRejected(err error) bool {
+	return strings.Contains(err.Error(), "ssh: unable to authenticate")
+}
+
 // === 已移除的认证相关方法 ===
 // 原来的 trySFTPUserWithDiagnostics, trySFTPWithCredentials,
 // checkSFTPSubsystem, tryWithAlternativeUsers, trySFTPWithUser
 // 等方法已被移除，因为它们包含了密码认证逻辑，不符合协议检测器的定位
 
 // 基于认证的检测方法（仅在用户提供有效认证信息时使用）
-func (s SFTPHelper) checkWithAuth(user string, authMethod ssh.AuthMethod) error {
-	netConn, err := net.DialTimeout("tcp", s.uri, s.timeout)
+func (s SFTPHelper) checkWithAuth(auth AuthOptions) error {
+	err := s.loginCheck(auth)
+	var hostKeyErr *sshfeature.HostKeyError
+	if errors.As(err, &hostKeyErr) {
+		return hostKeyErr
+	}
 	if err != nil {
 		return custom_error.ErrSFTPNotFound
 	}
-	defer netConn.Close()
+	return nil
+}
 
-	config := &ssh.ClientConfig{
-		User:            user,
-		Auth:            []ssh.AuthMethod{authMethod},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         s.timeout,
+// loginCheck 登录后读取根目录确认 SFTP 可用，返回实际的错误，例如认证失败或 ssh-agent 不可用
+func (s SFTPHelper) loginCheck(auth AuthOptions) error {
+	session, err := s.openSession(auth)
+	if err != nil {
+		return err
 	}
+	defer session.Close()
 
-	sshCon, channel, req, err := ssh.NewClientConn(netConn, s.uri, config)
-	if err != nil {
-		return custom_error.ErrSFTPNotFound
+	// 验证SFTP功能 - 尝试读取根目录
+	if _, err := session.client.ReadDir("/"); err != nil {
+		return fmt.Errorf("读取根目录失败: %w", err)
 	}
-	defer sshCon.Close()
+	return nil
+}
 
-	sshClient := ssh.NewClient(sshCon, channel, req)
-	ftp, err := sftp.NewClient(sshClient)
-	if err != nil {
-		return custom_error.ErrSFTPNotFound
+// RecordHostKeyError 登录因为主机密钥校验失败时记录原因，其它错误忽略
+func (d *SFTPDiagnostics) RecordHostKeyError(err error) {
+	var hostKeyErr *sshfeature.HostKeyError
+	if errors.As(err, &hostKeyErr) {
+		d.HostKeyError = hostKeyErr.Error()
 	}
-	defer ftp.Close()
+}
 
-	// 验证SFTP功能 - 尝试读取根目录
-	_, err = ftp.ReadDir("/")
-	if err != nil {
-		return custom_error.ErrSFTPNotFound
+// Metadata 转换为扫描结果的附加信息
+func (d SFTPDiagnostics) Metadata() map[string]string {
+	metadata := make(map[string]string)
+	if bannerInfo, err := sshfeature.ParseBanner(d.SSHBanner); err == nil {
+		metadata = bannerInfo.Metadata()
+	}
+	metadata["sftp_status"] = string(d.Status)
+	if d.HostKeyType != "" {
+		metadata["ssh_hostkey_type"] = d.HostKeyType
+		metadata["ssh_hostkey_sha256"] = d.HostKeySHA256
 	}
+	if d.HostKeyError != "" {
+		metadata["sftp_hostkey_error"] = d.HostKeyError
+	}
+	return metadata
+}
 
-	return nil
+// DefaultPorts 返回 SFTP 协议的常用端口
+func DefaultPorts() []int {
+	return []int{22}
+}
+
+func (s SFTPHelper) GetDefaultPorts() []int {
+	return DefaultPorts()
 }
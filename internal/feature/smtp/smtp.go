@@ -0,0 +1,309 @@
+package smtp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	"github.com/allanpk716/go-protocol-detector/internal/utils"
+)
+
+const (
+	maxReplyLines = 100
+
+	// implicitTLSPort SMTPS 端口（RFC 8314），只有这个端口先尝试 implicit TLS
+	implicitTLSPort = "465"
+)
+
+var (
+	// ErrNotSMTP 服务端没有返回 2xx/5xx 欢迎信息，或者 EHLO、HELO 都没有返回 250
+	ErrNotSMTP = errors.New("server is not smtp")
+	// ErrInvalidReply 服务端的响应不是 SMTP 应答格式
+	ErrInvalidReply = errors.New("invalid smtp reply")
+)
+
+type SMTPHelper struct {
+	heloName string
+	version  string
+}
+
+func NewSMTPHelper() *SMTPHelper {
+	smtp := SMTPHelper{
+		heloName: "localhost",
+		version:  "v0.1",
+	}
+	return &smtp
+}
+
+func (s SMTPHelper) GetVersion() string {
+	return s.version
+}
+
+// DefaultPorts 返回 SMTP 的常用端口：25 传输、465 implicit TLS 提交、587 提交
+func DefaultPorts() []int {
+	return []int{25, 465, 587}
+}
+
+func (s SMTPHelper) GetDefaultPorts() []int {
+	return DefaultPorts()
+}
+
+// Reply 一条 SMTP 应答，多行应答（RFC 5321 4.2.1）的每一行都在 Lines 中，不包含应答码
+type Reply struct {
+	Code  int
+	Lines []string
+}
+
+// readReply 读取一条可能是多行的 SMTP 应答：除最后一行是 "xyz " 外，每行都是 "xyz-"
+func readReply(r *bufio.Reader) (*Reply, error) {
+	reply := &Reply{}
+	for i := 0; i < maxReplyLines; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil && !(err == io.EOF && line != "") {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 3 {
+			return nil, ErrInvalidReply
+		}
+		code, err := strconv.Atoi(line[:3])
+		if err != nil || code < 200 || code > 599 || reply.Code != 0 && code != reply.Code {
+			return nil, ErrInvalidReply
+		}
+		reply.Code = code
+		if len(line) == 3 {
+			reply.Lines = append(reply.Lines, "")
+			return reply, nil
+		}
+		reply.Lines = append(reply.Lines, strings.TrimSpace(line[4:]))
+		if line[3] != '-' {
+			return reply, nil
+		}
+	}
+	return nil, ErrInvalidReply
+}
+
+// command 发送一条命令并读取应答
+func command(conn io.Writer, r *bufio.Reader, format string, args ...interface{}) (*Reply, error) {
+	if _, err := fmt.Fprintf(conn, format+"\r\n", args...); err != nil {
+		return nil, err
+	}
+	return readReply(r)
+}
+
+// SMTPInfo SMTP 服务端的欢迎信息、EHLO 声明的扩展以及 TLS 信息
+type SMTPInfo struct {
+	Greeting          []string       `json:"greeting"`            // 欢迎信息
+	GreetingCode      int            `json:"greeting_code"`       // 欢迎信息的应答码，220 表示可以提供服务，554 等 5xx 表示拒绝服务
+	ESMTP             bool           `json:"esmtp"`               // 是否支持 EHLO
+	EHLODomain        string         `json:"ehlo_domain"`         // EHLO 应答第一行，通常是服务端的主机名
+	Extensions        []string       `json:"extensions"`          // EHLO 声明的扩展
+	StartTLS          bool           `json:"starttls"`            // 是否声明 STARTTLS
+	AuthMechanisms    []string       `json:"auth_mechanisms"`     // 明文连接（implicit TLS 时为 TLS 连接）上声明的认证方式
+	Size              string         `json:"size"`                // SIZE 声明的最大邮件大小，0 表示不限制
+	Pipelining        bool           `json:"pipelining"`          // 是否声明 PIPELINING
+	PlaintextAuth     bool           `json:"plaintext_auth"`      // 未加密的连接上提供 AUTH PLAIN/LOGIN，口令会以明文传输
+	ImplicitTLS       bool           `json:"implicit_tls"`        // 连接建立后直接进行 TLS 握手，通常是 465 端口
+	StartTLSTested    bool           `json:"starttls_tested"`     // 是否尝试了 STARTTLS 升级
+	TLS               *utils.TLSInfo `json:"tls"`                 // implicit TLS 或 STARTTLS 升级后的 TLS 信息
+	TLSAuthMechanisms []string       `json:"tls_auth_mechanisms"` // STARTTLS 升级后 EHLO 声明的认证方式
+}
+
+// setExtensions 解析 EHLO 应答，第一行是服务端的主机名，之后每行是一个扩展
+func (i *SMTPInfo) setExtensions(lines []string) {
+	if len(lines) > 0 {
+		i.EHLODomain = lines[0]
+	}
+	for _, extension := range lines[1:] {
+		if extension == "" {
+			continue
+		}
+		i.Extensions = append(i.Extensions, extension)
+		fields := strings.Fields(extension)
+		switch keyword := strings.ToUpper(fields[0]); {
+		case keyword == "STARTTLS":
+			i.StartTLS = true
+		case keyword == "PIPELINING":
+			i.Pipelining = true
+		case keyword == "SIZE":
+			i.Size = "0"
+			if len(fields) > 1 {
+				i.Size = fields[1]
+			}
+		case keyword == "AUTH" || strings.HasPrefix(keyword, "AUTH="):
+			i.AuthMechanisms = appendMechanisms(i.AuthMechanisms, extension)
+		}
+	}
+}
+
+// parseAuthMechanisms 从 EHLO 应答中取出 AUTH 声明的认证方式
+func parseAuthMechanisms(lines []string) []string {
+	mechanisms := make([]string, 0)
+	for _, extension := range lines[1:] {
+		keyword := strings.ToUpper(strings.SplitN(extension, " ", 2)[0])
+		if keyword == "AUTH" || strings.HasPrefix(keyword, "AUTH=") {
+			mechanisms = appendMechanisms(mechanisms, extension)
+		}
+	}
+	return mechanisms
+}
+
+// appendMechanisms 解析 "AUTH PLAIN LOGIN" 以及旧版本的 "AUTH=PLAIN LOGIN"，去掉重复
+func appendMechanisms(mechanisms []string, extension string) []string {
+	fields := strings.Fields(strings.ToUpper(extension))
+	fields[0] = strings.TrimPrefix(strings.TrimPrefix(fields[0], "AUTH"), "=")
+	for _, mechanism := range fields {
+		if mechanism == "" {
+			continue
+		}
+		exists := false
+		for _, known := range mechanisms {
+			exists = exists || known == mechanism
+		}
+		if !exists {
+			mechanisms = append(mechanisms, mechanism)
+		}
+	}
+	return mechanisms
+}
+
+// hasPlaintextMechanism 是否包含以明文（base64）传输口令的 PLAIN、LOGIN
+func hasPlaintextMechanism(mechanisms []string) bool {
+	for _, mechanism := range mechanisms {
+		if mechanism == "PLAIN" || mechanism == "LOGIN" {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 使用明文连接，读取不到欢迎信息时再尝试 implicit TLS；465 端口反过来先尝试 implicit TLS，
+// 避免在 TLS 端口上等待欢迎信息直到超时，也避免在明文端口上发送 ClientHello
+// 收到 220 欢迎信息后发送 EHLO 解析扩展，不支持 EHLO 时退回 HELO；其它 2xx/5xx 欢迎信息（例如 554 拒绝服务）只记录应答码
+// startTLS 为 true 且服务端声明 STARTTLS 时升级连接并记录 TLS 信息
+func (s SMTPHelper) Check(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc, startTLS bool) (*SMTPInfo, error) {
+	_, port, _ := net.SplitHostPort(addr)
+	implicitTLS := port == implicitTLSPort
+	info, err := s.check(addr, implicitTLS, timeouts, dial, startTLS)
+	if err == nil {
+		return info, nil
+	}
+	return s.check(addr, !implicitTLS, timeouts, dial, startTLS)
+}
+
+func (s SMTPHelper) check(addr string, implicitTLS bool, timeouts common.Timeouts, dial dialer.DialContextFunc, startTLS bool) (*SMTPInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	host, _, _ := net.SplitHostPort(addr)
+	info := &SMTPInfo{ImplicitTLS: implicitTLS, Extensions: make([]string, 0), AuthMechanisms: make([]string, 0)}
+	if implicitTLS {
+		if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+			return nil, err
+		}
+		tlsConn, tlsInfo, err := utils.TLSClient(conn, utils.InsecureTLSConfig(host))
+		if err != nil {
+			return nil, err
+		}
+		conn, info.TLS = tlsConn, tlsInfo
+	}
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Read)); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	greeting, err := readReply(reader)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 5321 3.1：服务端以 220 欢迎，或者以 554 等 5xx 表示不提供服务
+	if greeting.Code/100 != 2 && greeting.Code/100 != 5 {
+		return nil, ErrNotSMTP
+	}
+	info.Greeting = greeting.Lines
+	info.GreetingCode = greeting.Code
+	if greeting.Code != 220 {
+		// 服务端拒绝服务，之后的命令都会被拒绝
+		command(conn, reader, "QUIT")
+		return info, nil
+	}
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		return nil, err
+	}
+	ehlo, err := command(conn, reader, "EHLO %s", s.heloName)
+	if err != nil {
+		return nil, err
+	}
+	if ehlo.Code != 250 {
+		// 只支持 RFC 821 的服务端，FTP 等同样以 220 开头的服务会在这里被排除
+		helo, err := command(conn, reader, "HELO %s", s.heloName)
+		if err != nil || helo.Code != 250 {
+			return nil, ErrNotSMTP
+		}
+		command(conn, reader, "QUIT")
+		return info, nil
+	}
+	info.ESMTP = true
+	info.setExtensions(ehlo.Lines)
+	info.PlaintextAuth = !implicitTLS && hasPlaintextMechanism(info.AuthMechanisms)
+
+	if startTLS && info.StartTLS && !implicitTLS {
+		info.StartTLSTested = true
+		// 升级失败不影响检测结果，连接状态未知，不再发送 QUIT
+		reply, err := command(conn, reader, "STARTTLS")
+		if err != nil || reply.Code != 220 {
+			return info, nil
+		}
+		tlsConn, tlsInfo, err := utils.TLSClient(conn, utils.InsecureTLSConfig(host))
+		if err != nil {
+			return info, nil
+		}
+		info.TLS = tlsInfo
+		conn, reader = tlsConn, bufio.NewReader(tlsConn)
+		if ehlo, err := command(conn, reader, "EHLO %s", s.heloName); err == nil && ehlo.Code == 250 {
+			info.TLSAuthMechanisms = parseAuthMechanisms(ehlo.Lines)
+		}
+	}
+
+	command(conn, reader, "QUIT")
+	return info, nil
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i SMTPInfo) Metadata() map[string]string {
+	metadata := map[string]string{
+		"smtp_banner":         strings.Join(i.Greeting, " | "),
+		"smtp_code":           strconv.Itoa(i.GreetingCode),
+		"smtp_esmtp":          fmt.Sprintf("%v", i.ESMTP),
+		"smtp_ehlo":           i.EHLODomain,
+		"smtp_extensions":     strings.Join(i.Extensions, ","),
+		"smtp_starttls":       fmt.Sprintf("%v", i.StartTLS),
+		"smtp_auth":           strings.Join(i.AuthMechanisms, ","),
+		"smtp_pipelining":     fmt.Sprintf("%v", i.Pipelining),
+		"smtp_plaintext_auth": fmt.Sprintf("%v", i.PlaintextAuth),
+		"smtp_implicit_tls":   fmt.Sprintf("%v", i.ImplicitTLS),
+	}
+	if i.Size != "" {
+		metadata["smtp_size"] = i.Size
+	}
+	if i.TLS != nil {
+		for key, value := range i.TLS.Metadata("smtp_tls_") {
+			metadata[key] = value
+		}
+	}
+	if i.StartTLSTested {
+		metadata["smtp_starttls_ok"] = fmt.Sprintf("%v", i.TLS != nil)
+		metadata["smtp_tls_auth"] = strings.Join(i.TLSAuthMechanisms, ",")
+	}
+	return metadata
+}
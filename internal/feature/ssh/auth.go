@@ -0,0 +1,133 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// errAuthProbe 认证方法探测时所有回调都返回该错误，不会向服务端提交任何凭据
+var errAuthProbe = errors.New("auth method probe")
+
+// attemptedMethods 从 x/crypto/ssh 认证失败的错误信息中提取尝试过的方法
+var attemptedMethods = regexp.MustCompile(`attempted methods \[([^\]]*)\]`)
+
+// gssapiFailure 服务端声明了 gssapi-with-mic 但不支持 krb5（例如没有 keytab）时，
+// 会用 USERAUTH_FAILURE 回复 GSSAPI 请求，x/crypto/ssh 期望 USERAUTH_GSSAPI_RESPONSE 而返回该错误
+var gssapiFailure = regexp.MustCompile(`unexpected message type 51\b`)
+
+// authMethodOrder 报告中认证方法的顺序
+var authMethodOrder = []string{"none", "publickey", "password", "keyboard-interactive", "gssapi-with-mic"}
+
+// AuthInfo 服务端在 "none" 认证失败时声明的认证方法
+type AuthInfo struct {
+	Methods         []string     `json:"methods"`            // 服务端允许的认证方法
+	PasswordEnabled bool         `json:"password_enabled"`   // 是否允许 password 或 keyboard-interactive
+	HostKey         *HostKeyInfo `json:"host_key,omitempty"` // 同一连接上获取的主机密钥
+}
+
+// probeGSSAPIClient 只用于确认服务端接受 gssapi-with-mic，不产生任何令牌
+type probeGSSAPIClient struct {
+	record func()
+}
+
+func (c probeGSSAPIClient) InitSecContext(target string, token []byte, isGSSDelegCreds bool) ([]byte, bool, error) {
+	c.record()
+	return nil, false, errAuthProbe
+}
+
+func (c probeGSSAPIClient) GetMIC(micField []byte) ([]byte, error) {
+	return nil, errAuthProbe
+}
+
+func (c probeGSSAPIClient) DeleteSecContext() error {
+	return nil
+}
+
+// AuthMethods 以 "none" 方式认证，根据服务端返回的可用方法列表报告支持的认证方法，不提交任何凭据，
+// 密钥交换时顺带记录主机密钥
+// x/crypto/ssh 只会调用服务端声明过的方法，所以这里用回调记录哪些方法被调用：
+// publickey、password 的回调在发送数据前执行；keyboard-interactive 需要与服务端交互，
+// 结合最终错误信息中的 attempted methods 判断；gssapi-with-mic 放在最后，它的错误就是最终错误，
+// 服务端不支持 krb5 时返回的 USERAUTH_FAILURE 同样说明声明了该方法
+func (s SSHHelper) AuthMethods(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*AuthInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		return nil, err
+	}
+
+	advertised := make(map[string]bool)
+	record := func(method string) func() {
+		return func() { advertised[method] = true }
+	}
+	handshakeDone := false
+	var hostKey *HostKeyInfo
+	config := &gossh.ClientConfig{
+		User: "protocol-detector",
+		Auth: []gossh.AuthMethod{
+			gossh.PublicKeysCallback(func() ([]gossh.Signer, error) {
+				record("publickey")()
+				return nil, nil
+			}),
+			gossh.PasswordCallback(func() (string, error) {
+				record("password")()
+				return "", errAuthProbe
+			}),
+			gossh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				record("keyboard-interactive")()
+				return nil, errAuthProbe
+			}),
+			gossh.GSSAPIWithMICAuthMethod(probeGSSAPIClient{record: record("gssapi-with-mic")}, "protocol-detector"),
+		},
+		HostKeyCallback: func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+			handshakeDone = true
+			hostKey = NewHostKeyInfo(key)
+			return nil
+		},
+		ClientVersion: "SSH-2.0-ProtocolDetector",
+	}
+
+	client, _, _, err := gossh.NewClientConn(conn, addr, config)
+	if err == nil {
+		// 不需要认证即可登录
+		client.Close()
+		advertised["none"] = true
+	} else if !handshakeDone {
+		return nil, err
+	} else if gssapiFailure.MatchString(err.Error()) {
+		advertised["gssapi-with-mic"] = true
+	} else if match := attemptedMethods.FindStringSubmatch(err.Error()); match != nil {
+		for _, method := range strings.Fields(match[1]) {
+			if method != "none" {
+				advertised[method] = true
+			}
+		}
+	}
+
+	info := &AuthInfo{Methods: make([]string, 0), HostKey: hostKey}
+	for _, method := range authMethodOrder {
+		if advertised[method] {
+			info.Methods = append(info.Methods, method)
+		}
+	}
+	info.PasswordEnabled = advertised["password"] || advertised["keyboard-interactive"]
+	return info, nil
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i AuthInfo) Metadata() map[string]string {
+	return map[string]string{
+		"ssh_auth_methods":  strings.Join(i.Methods, ","),
+		"ssh_password_auth": fmt.Sprintf("%v", i.PasswordEnabled),
+	}
+}
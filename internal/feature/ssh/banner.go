@@ -0,0 +1,133 @@
+package ssh
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+)
+
+// softwareNames 常见实现的标识与规范名称
+var softwareNames = map[string]string{
+	"openssh":             "OpenSSH",
+	"openssh_for_windows": "OpenSSH",
+	"dropbear":            "dropbear",
+	"libssh":              "libssh",
+	"libssh2":             "libssh2",
+	"cisco":               "Cisco",
+	"rosssh":              "RouterOS",
+	"paramiko":            "paramiko",
+	"go":                  "Go",
+	"mod_sftp":            "ProFTPD mod_sftp",
+	"bitvise":             "Bitvise",
+	"wingftp":             "Wing FTP",
+}
+
+// osHints 版本注释或软件标识中出现的操作系统关键字
+var osHints = []struct {
+	keyword string
+	os      string
+}{
+	{"ubuntu", "Ubuntu"},
+	{"debian", "Debian"},
+	{"raspbian", "Raspbian"},
+	{"freebsd", "FreeBSD"},
+	{"netbsd", "NetBSD"},
+	{"openbsd", "OpenBSD"},
+	{"for_windows", "Windows"},
+	{"rosssh", "RouterOS"},
+}
+
+// BannerInfo 解析后的 SSH 版本标识 SSH-protoversion-softwareversion SP comments
+type BannerInfo struct {
+	Raw             string `json:"raw"`              // 原始版本标识
+	ProtocolVersion string `json:"protocol_version"` // 协议版本，例如 2.0、1.99
+	Software        string `json:"software"`         // 软件名称，例如 OpenSSH、dropbear、RouterOS
+	SoftwareVersion string `json:"software_version"` // 软件版本，例如 8.9p1
+	Comments        string `json:"comments"`         // 注释，通常是发行版补丁号，例如 Ubuntu-3ubuntu0.6
+	OS              string `json:"os"`               // 根据软件标识和注释推断的操作系统，无法推断时为空
+}
+
+// ParseBanner 解析 SSH 版本标识（RFC 4253 4.2）
+func ParseBanner(banner string) (*BannerInfo, error) {
+	banner = strings.TrimRight(banner, "\r\n")
+	if !strings.HasPrefix(banner, "SSH-") {
+		return nil, ErrNotSSH
+	}
+	protocolVersion, rest, ok := strings.Cut(banner[len("SSH-"):], "-")
+	if !ok || protocolVersion == "" {
+		return nil, ErrNotSSH
+	}
+
+	info := &BannerInfo{
+		Raw:             banner,
+		ProtocolVersion: protocolVersion,
+	}
+	softwareVersion, comments, _ := strings.Cut(rest, " ")
+	info.Comments = strings.TrimSpace(comments)
+
+	name, version := splitSoftwareVersion(softwareVersion)
+	info.Software = name
+	if canonical, ok := softwareNames[strings.ToLower(name)]; ok {
+		info.Software = canonical
+	}
+	info.SoftwareVersion = version
+
+	hint := strings.ToLower(softwareVersion + " " + info.Comments)
+	for _, h := range osHints {
+		if strings.Contains(hint, h.keyword) {
+			info.OS = h.os
+			break
+		}
+	}
+	return info, nil
+}
+
+// splitSoftwareVersion 在第一个后面紧跟数字的 '_' 或 '-' 处拆分软件名称和版本，
+// 例如 OpenSSH_8.9p1、OpenSSH_for_Windows_8.1、Cisco-1.25、libssh-0.6.0；没有版本号时全部作为名称
+func splitSoftwareVersion(s string) (string, string) {
+	for i := 0; i+1 < len(s); i++ {
+		if (s[i] == '_' || s[i] == '-') && s[i+1] >= '0' && s[i+1] <= '9' {
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// Banner 发送客户端版本标识并读取、解析服务端版本标识
+func (s SSHHelper) Banner(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*BannerInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Read)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(s.SenderPackage); err != nil {
+		return nil, err
+	}
+	banner, err := readBanner(bufio.NewReader(conn))
+	if err != nil {
+		return nil, err
+	}
+	return ParseBanner(banner)
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i BannerInfo) Metadata() map[string]string {
+	metadata := map[string]string{
+		"ssh_protocol":         i.ProtocolVersion,
+		"ssh_software":         i.Software,
+		"ssh_software_version": i.SoftwareVersion,
+	}
+	if i.Comments != "" {
+		metadata["ssh_comments"] = i.Comments
+	}
+	if i.OS != "" {
+		metadata["ssh_os"] = i.OS
+	}
+	return metadata
+}
@@ -0,0 +1,61 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// errHostKeyCaptured 拿到主机密钥后主动中止握手
+var errHostKeyCaptured = errors.New("host key captured")
+
+// HostKeyInfo 服务端主机密钥
+type HostKeyInfo struct {
+	Type   string `json:"type"`   // 密钥类型，例如 ssh-ed25519
+	SHA256 string `json:"sha256"` // 与 ssh-keygen -l 一致的 SHA256:base64 指纹
+}
+
+// NewHostKeyInfo 从公钥生成主机密钥信息
+func NewHostKeyInfo(key gossh.PublicKey) *HostKeyInfo {
+	return &HostKeyInfo{
+		Type:   key.Type(),
+		SHA256: gossh.FingerprintSHA256(key),
+	}
+}
+
+// HostKey 完成密钥交换拿到服务端主机密钥后立即断开，不进行认证
+func (s SSHHelper) HostKey(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*HostKeyInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		return nil, err
+	}
+
+	var info *HostKeyInfo
+	config := &gossh.ClientConfig{
+		User: "protocol-detector",
+		HostKeyCallback: func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+			info = NewHostKeyInfo(key)
+			return errHostKeyCaptured
+		},
+		ClientVersion: "SSH-2.0-ProtocolDetector",
+	}
+	if _, _, _, err := gossh.NewClientConn(conn, addr, config); info == nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i HostKeyInfo) Metadata() map[string]string {
+	return map[string]string{
+		"ssh_hostkey_type":   i.Type,
+		"ssh_hostkey_sha256": i.SHA256,
+	}
+}
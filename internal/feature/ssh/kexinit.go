@@ -0,0 +1,281 @@
+package ssh
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+)
+
+const (
+	msgKexInit = 20
+
+	maxBannerLines = 20
+	maxPacketSize  = 35000
+)
+
+var (
+	// ErrNotSSH 服务端没有返回 SSH 版本标识
+	ErrNotSSH = errors.New("server did not send an ssh identification string")
+	// ErrNoKexInit 服务端没有发送 SSH_MSG_KEXINIT
+	ErrNoKexInit = errors.New("server did not send ssh kexinit")
+)
+
+// clientAlgorithms 发送给服务端的 KEXINIT，尽量覆盖新旧算法，避免服务端因为没有共同算法提前断开
+var clientAlgorithms = [10]string{
+	"curve25519-sha256,curve25519-sha256@libssh.org,ecdh-sha2-nistp256,ecdh-sha2-nistp384,ecdh-sha2-nistp521," +
+		"diffie-hellman-group-exchange-sha256,diffie-hellman-group16-sha512,diffie-hellman-group18-sha512," +
+		"diffie-hellman-group14-sha256,diffie-hellman-group14-sha1,diffie-hellman-group1-sha1,diffie-hellman-group-exchange-sha1",
+	"ssh-ed25519,ecdsa-sha2-nistp256,ecdsa-sha2-nistp384,ecdsa-sha2-nistp521,rsa-sha2-512,rsa-sha2-256,ssh-rsa,ssh-dss",
+	"chacha20-poly1305@openssh.com,aes128-ctr,aes192-ctr,aes256-ctr,aes128-gcm@openssh.com,aes256-gcm@openssh.com,aes128-cbc,aes256-cbc,3des-cbc",
+	"chacha20-poly1305@openssh.com,aes128-ctr,aes192-ctr,aes256-ctr,aes128-gcm@openssh.com,aes256-gcm@openssh.com,aes128-cbc,aes256-cbc,3des-cbc",
+	"hmac-sha2-256-etm@openssh.com,hmac-sha2-512-etm@openssh.com,hmac-sha2-256,hmac-sha2-512,hmac-sha1,hmac-md5",
+	"hmac-sha2-256-etm@openssh.com,hmac-sha2-512-etm@openssh.com,hmac-sha2-256,hmac-sha2-512,hmac-sha1,hmac-md5",
+	"none,zlib@openssh.com,zlib",
+	"none,zlib@openssh.com,zlib",
+	"",
+	"",
+}
+
+// weakKex 已知不安全的密钥交换算法
+var weakKex = map[string]bool{
+	"diffie-hellman-group1-sha1":               true,
+	"diffie-hellman-group14-sha1":              true,
+	"diffie-hellman-group-exchange-sha1":       true,
+	"rsa1024-sha1":                             true,
+	"gss-group1-sha1-toWM5Slw5Ew8Mqkay+al2g==": true,
+}
+
+// weakHostKey 已知不安全的主机密钥算法（DSA、SHA-1 签名的 RSA）
+var weakHostKey = map[string]bool{
+	"ssh-dss":                      true,
+	"ssh-dss-cert-v01@openssh.com": true,
+	"ssh-rsa":                      true,
+	"ssh-rsa-cert-v01@openssh.com": true,
+}
+
+// weakMAC 已知不安全的 MAC 算法
+var weakMAC = map[string]bool{
+	"none":                         true,
+	"hmac-md5":                     true,
+	"hmac-md5-96":                  true,
+	"hmac-sha1-96":                 true,
+	"hmac-md5-etm@openssh.com":     true,
+	"hmac-md5-96-etm@openssh.com":  true,
+	"hmac-sha1-96-etm@openssh.com": true,
+	"umac-64@openssh.com":          true,
+	"umac-64-etm@openssh.com":      true,
+}
+
+// AlgorithmInfo 服务端 KEXINIT 中声明的算法
+type AlgorithmInfo struct {
+	Kex         []string `json:"kex"`         // 密钥交换算法
+	HostKey     []string `json:"host_key"`    // 主机密钥算法
+	Ciphers     []string `json:"ciphers"`     // 加密算法（两个方向合并）
+	MACs        []string `json:"macs"`        // MAC 算法（两个方向合并）
+	Compression []string `json:"compression"` // 压缩算法（两个方向合并）
+	Weak        []string `json:"weak"`        // 不安全的算法，格式为 类别:算法名
+}
+
+// readBanner 读取服务端版本标识，RFC 4253 允许在版本标识之前发送其它文本行
+func readBanner(r *bufio.Reader) (string, error) {
+	for i := 0; i < maxBannerLines; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "SSH-") {
+			return line, nil
+		}
+	}
+	return "", ErrNotSSH
+}
+
+// buildPacket 按照 RFC 4253 6 节构造未加密的二进制包
+func buildPacket(payload []byte) []byte {
+	padding := 8 - (5+len(payload))%8
+	if padding < 4 {
+		padding += 8
+	}
+	packet := make([]byte, 5+len(payload)+padding)
+	binary.BigEndian.PutUint32(packet, uint32(1+len(payload)+padding))
+	packet[4] = byte(padding)
+	copy(packet[5:], payload)
+	return packet
+}
+
+// readPacket 读取一个未加密的二进制包，返回 payload
+func readPacket(r io.Reader) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	padding := uint32(header[4])
+	if length < 1+padding || length > maxPacketSize {
+		return nil, fmt.Errorf("invalid ssh packet length %d", length)
+	}
+	body := make([]byte, length-1)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body[:len(body)-int(padding)], nil
+}
+
+// buildKexInit 构造客户端的 SSH_MSG_KEXINIT
+func buildKexInit() []byte {
+	payload := make([]byte, 17)
+	payload[0] = msgKexInit
+	rand.Read(payload[1:17])
+	for _, list := range clientAlgorithms {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(list)))
+		payload = append(payload, list...)
+	}
+	// first_kex_packet_follows + reserved
+	return append(payload, 0, 0, 0, 0, 0)
+}
+
+// ParseKexInit 解析 SSH_MSG_KEXINIT（RFC 4253 7.1），返回 10 个 name-list
+func ParseKexInit(payload []byte) ([10][]string, error) {
+	var lists [10][]string
+	if len(payload) < 17 || payload[0] != msgKexInit {
+		return lists, ErrNoKexInit
+	}
+
+	rest := payload[17:]
+	for i := range lists {
+		if len(rest) < 4 {
+			return lists, fmt.Errorf("kexinit truncated at name-list %d", i)
+		}
+		n := binary.BigEndian.Uint32(rest)
+		if uint32(len(rest)-4) < n {
+			return lists, fmt.Errorf("kexinit truncated at name-list %d", i)
+		}
+		if n > 0 {
+			lists[i] = strings.Split(string(rest[4:4+n]), ",")
+		}
+		rest = rest[4+n:]
+	}
+	return lists, nil
+}
+
+// NewAlgorithmInfo 根据 KEXINIT 的 name-list 生成算法报告
+func NewAlgorithmInfo(lists [10][]string) *AlgorithmInfo {
+	info := &AlgorithmInfo{
+		Kex:         lists[0],
+		HostKey:     lists[1],
+		Ciphers:     mergeNames(lists[2], lists[3]),
+		MACs:        mergeNames(lists[4], lists[5]),
+		Compression: mergeNames(lists[6], lists[7]),
+		Weak:        make([]string, 0),
+	}
+
+	for _, name := range info.Kex {
+		if weakKex[name] {
+			info.Weak = append(info.Weak, "kex:"+name)
+		}
+	}
+	for _, name := range info.HostKey {
+		if weakHostKey[name] {
+			info.Weak = append(info.Weak, "hostkey:"+name)
+		}
+	}
+	for _, name := range info.Ciphers {
+		if isWeakCipher(name) {
+			info.Weak = append(info.Weak, "cipher:"+name)
+		}
+	}
+	for _, name := range info.MACs {
+		if weakMAC[name] {
+			info.Weak = append(info.Weak, "mac:"+name)
+		}
+	}
+	return info
+}
+
+// isWeakCipher CBC 模式、RC4、DES/3DES、Blowfish、CAST 以及不加密都视为不安全
+func isWeakCipher(name string) bool {
+	if name == "none" || strings.HasSuffix(name, "-cbc") || strings.HasSuffix(name, "-cbc@openssh.com") {
+		return true
+	}
+	for _, prefix := range []string{"arcfour", "des", "3des", "blowfish", "cast128", "rijndael-cbc"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeNames 合并两个方向的算法列表，保持服务端的优先顺序并去重
+func mergeNames(a, b []string) []string {
+	merged := make([]string, 0, len(a))
+	seen := make(map[string]bool)
+	for _, name := range append(append([]string{}, a...), b...) {
+		if !seen[name] {
+			seen[name] = true
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
+
+// CheckAlgorithms 交换版本标识和 KEXINIT，读取服务端声明的算法，不进行后续密钥交换
+func (s SSHHelper) CheckAlgorithms(addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*AlgorithmInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Read)); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	if _, err := readBanner(reader); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(s.SenderPackage); err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(buildPacket(buildKexInit())); err != nil {
+		return nil, err
+	}
+	// 服务端可能先发送 SSH_MSG_IGNORE / SSH_MSG_DEBUG 等消息
+	for i := 0; i < 5; i++ {
+		payload, err := readPacket(reader)
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) > 0 && payload[0] == msgKexInit {
+			lists, err := ParseKexInit(payload)
+			if err != nil {
+				return nil, err
+			}
+			return NewAlgorithmInfo(lists), nil
+		}
+	}
+	return nil, ErrNoKexInit
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i AlgorithmInfo) Metadata() map[string]string {
+	return map[string]string{
+		"ssh_kex":         strings.Join(i.Kex, ","),
+		"ssh_hostkey":     strings.Join(i.HostKey, ","),
+		"ssh_ciphers":     strings.Join(i.Ciphers, ","),
+		"ssh_macs":        strings.Join(i.MACs, ","),
+		"ssh_compression": strings.Join(i.Compression, ","),
+		"ssh_weak":        strings.Join(i.Weak, ","),
+	}
+}
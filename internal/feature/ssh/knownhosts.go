@@ -0,0 +1,135 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// KnownHostsMode 主机密钥校验模式
+type KnownHostsMode string
+
+const (
+	KnownHostsInsecure  KnownHostsMode = "insecure"   // 不校验，只记录主机密钥
+	KnownHostsStrict    KnownHostsMode = "strict"     // 主机必须已在 known_hosts 中且密钥一致
+	KnownHostsAcceptNew KnownHostsMode = "accept-new" // 新主机的密钥写入 known_hosts，已知主机的密钥必须一致
+)
+
+// HostKeyError 主机密钥校验失败：密钥与 known_hosts 不一致、密钥已被吊销，或者 strict 模式下主机不在 known_hosts 中
+type HostKeyError struct {
+	Host    string      // 目标地址 host:port
+	Key     HostKeyInfo // 服务端提供的主机密钥
+	Known   []string    // known_hosts 中该主机的密钥，格式为 类型 指纹，为空表示未知主机
+	Revoked bool        // 服务端密钥在 known_hosts 中被标记为 @revoked
+}
+
+func (e *HostKeyError) Error() string {
+	switch {
+	case e.Revoked:
+		return fmt.Sprintf("host key verification failed for %s: %s %s is revoked", e.Host, e.Key.Type, e.Key.SHA256)
+	case len(e.Known) == 0:
+		return fmt.Sprintf("host key verification failed for %s: host is not in known_hosts (%s %s)", e.Host, e.Key.Type, e.Key.SHA256)
+	default:
+		return fmt.Sprintf("host key verification failed for %s: got %s %s, known_hosts has %s",
+			e.Host, e.Key.Type, e.Key.SHA256, strings.Join(e.Known, ", "))
+	}
+}
+
+// HostKeyVerifier 按照 known_hosts 文件校验主机密钥，可以在多个连接间共享
+type HostKeyVerifier struct {
+	path string
+	mode KnownHostsMode
+	mu   sync.Mutex
+}
+
+// NewHostKeyVerifier 创建主机密钥校验器，path 为空时使用 ~/.ssh/known_hosts
+func NewHostKeyVerifier(path string, mode KnownHostsMode) (*HostKeyVerifier, error) {
+	switch mode {
+	case KnownHostsInsecure, KnownHostsStrict, KnownHostsAcceptNew:
+	default:
+		return nil, fmt.Errorf("unknown known_hosts mode %q, want strict, accept-new or insecure", mode)
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	return &HostKeyVerifier{path: path, mode: mode}, nil
+}
+
+// Callback 返回记录并校验主机密钥的 HostKeyCallback，校验器为 nil 时只记录不校验
+func (v *HostKeyVerifier) Callback(info *HostKeyInfo) gossh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		*info = *NewHostKeyInfo(key)
+		if v == nil || v.mode == KnownHostsInsecure {
+			return nil
+		}
+		return v.verify(hostname, remote, key)
+	}
+}
+
+func (v *HostKeyVerifier) verify(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	hostKeyErr := &HostKeyError{Host: hostname, Key: *NewHostKeyInfo(key)}
+	err := v.check(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	var revokedErr *knownhosts.RevokedError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &revokedErr):
+		hostKeyErr.Revoked = true
+		return hostKeyErr
+	case errors.As(err, &keyErr) && len(keyErr.Want) > 0:
+		for _, known := range keyErr.Want {
+			hostKeyErr.Known = append(hostKeyErr.Known, known.Key.Type()+" "+gossh.FingerprintSHA256(known.Key))
+		}
+		return hostKeyErr
+	case errors.As(err, &keyErr) || errors.Is(err, os.ErrNotExist):
+		// 未知主机
+		if v.mode == KnownHostsStrict {
+			return hostKeyErr
+		}
+		return v.add(hostname, key)
+	default:
+		return err
+	}
+}
+
+// check 每次重新读取 known_hosts，其它连接在 accept-new 模式下写入的主机马上生效
+func (v *HostKeyVerifier) check(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	callback, err := knownhosts.New(v.path)
+	if err != nil {
+		return err
+	}
+	// knownhosts 要求 remote 是 host:port 形式，经过代理或自定义拨号函数时可能不是，优先使用的 hostname 已经足够
+	if _, _, err := net.SplitHostPort(remote.String()); err != nil {
+		remote = &net.TCPAddr{}
+	}
+	return callback(hostname, remote, key)
+}
+
+// add 把新主机的密钥追加到 known_hosts
+func (v *HostKeyVerifier) add(hostname string, key gossh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(v.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
@@ -32,3 +32,12 @@ func NewSSHHelper() *SSHHelper {
 func (s SSHHelper) GetVersion() string {
 	return s.version
 }
+
+// DefaultPorts 返回 SSH 协议的常用端口
+func DefaultPorts() []int {
+	return []int{22}
+}
+
+func (s SSHHelper) GetDefaultPorts() []int {
+	return DefaultPorts()
+}
@@ -0,0 +1,180 @@
+package telnet
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxBannerLen 最多读取的文本长度，遇到登录提示符或超时也会停止
+const maxBannerLen = 4096
+
+// promptPattern 登录提示或 shell 提示符，只匹配最后一行
+var promptPattern = regexp.MustCompile(`(?i)((login|username|user name|user|password)\s*:|[>#$%])\s*$`)
+
+// optionNames 常见 Telnet 选项的名称（IANA Telnet Options）
+var optionNames = map[byte]string{
+	0:  "BINARY",
+	1:  "ECHO",
+	3:  "SUPPRESS-GO-AHEAD",
+	5:  "STATUS",
+	6:  "TIMING-MARK",
+	24: "TERMINAL-TYPE",
+	31: "NAWS",
+	32: "TERMINAL-SPEED",
+	33: "TOGGLE-FLOW-CONTROL",
+	34: "LINEMODE",
+	35: "X-DISPLAY-LOCATION",
+	36: "OLD-ENVIRON",
+	37: "AUTHENTICATION",
+	38: "ENCRYPT",
+	39: "NEW-ENVIRON",
+}
+
+// OptionName 返回选项的名称，未知选项返回编号
+func OptionName(option byte) string {
+	if name, ok := optionNames[option]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", option)
+}
+
+// deviceSignatures 按顺序匹配欢迎信息和提示符，识别设备类型
+var deviceSignatures = []struct {
+	device   string
+	keywords []string
+}{
+	{"Cisco IOS", []string{"user access verification", "cisco"}},
+	{"MikroTik RouterOS", []string{"mikrotik", "routeros"}},
+	{"Windows Telnet Server", []string{"microsoft telnet", "welcome to microsoft"}},
+	{"BusyBox", []string{"busybox", "built-in shell (ash)"}},
+}
+
+// TelnetInfo Telnet 服务端的选项协商记录、欢迎信息和登录提示
+type TelnetInfo struct {
+	Do     []string `json:"do"`     // 服务端请求客户端启用的选项（IAC DO）
+	Will   []string `json:"will"`   // 服务端声明启用的选项（IAC WILL）
+	Banner string   `json:"banner"` // 去掉控制字符后的欢迎信息，包含提示符
+	Prompt string   `json:"prompt"` // 最后一行的登录提示或提示符
+	Device string   `json:"device"` // 根据文本识别的设备类型，无法识别时为空
+}
+
+// Banner 拒绝服务端的所有选项，读取欢迎信息直到出现登录提示、达到 maxBannerLen 或超时
+// 与 Check 一样只接受 Telnet，其它协议返回 ErrNotTelnet
+func (t *TelnetHelper) Banner() (*TelnetInfo, error) {
+	text, err := t.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.verify(text); err != nil {
+		return nil, err
+	}
+	return newTelnetInfo(t.do, t.will, text), nil
+}
+
+// read 拒绝服务端的所有选项并读取文本，直到出现登录提示、达到 maxBannerLen 或超时
+// 没有收到过选项协商时，一旦文本能识别为其它协议就停止读取；没有收到任何数据或选项时返回读取的错误
+func (t *TelnetHelper) read() ([]byte, error) {
+	if t.readTimeout > 0 {
+		if err := t.Conn.SetDeadline(time.Now().Add(t.readTimeout)); err != nil {
+			return nil, err
+		}
+	}
+	text := make([]byte, 0, 256)
+	var err error
+	for len(text) < maxBannerLen {
+		var b byte
+		var retry bool
+		b, retry, err = t.tryReadByte()
+		if err != nil {
+			break
+		}
+		if retry {
+			continue
+		}
+		text = append(text, b)
+		// 服务端在提示符后等待输入，缓冲区为空时不再等待
+		if t.r.Buffered() == 0 && promptPattern.Match(lastLine(text)) {
+			break
+		}
+		// 其它协议发送欢迎信息后等待客户端，不等到超时
+		if !t.negotiated && (b == '\n' || len(text) == otherProtocolProbeLen) && otherProtocol(text) != "" {
+			break
+		}
+	}
+	if len(text) == 0 && !t.negotiated {
+		return nil, err
+	}
+	return text, nil
+}
+
+func newTelnetInfo(do, will, text []byte) *TelnetInfo {
+	info := &TelnetInfo{Do: make([]string, 0, len(do)), Will: make([]string, 0, len(will))}
+	for _, option := range do {
+		info.Do = append(info.Do, OptionName(option))
+	}
+	for _, option := range will {
+		info.Will = append(info.Will, OptionName(option))
+	}
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(cleanText(text), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	info.Banner = strings.Join(lines, "\n")
+	if len(lines) > 0 && promptPattern.MatchString(lines[len(lines)-1]) {
+		info.Prompt = lines[len(lines)-1]
+	}
+	info.Device = classify(info.Banner)
+	return info
+}
+
+// cleanText 去掉 \r 以及其它控制字符，只保留换行和 Tab
+func cleanText(text []byte) string {
+	var builder strings.Builder
+	for _, r := range strings.ToValidUTF8(string(text), "") {
+		if r == '\n' || r == '\t' || r >= 0x20 && r != 0x7f {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+func lastLine(text []byte) []byte {
+	for i := len(text) - 1; i >= 0; i-- {
+		if text[i] == '\n' {
+			return text[i+1:]
+		}
+	}
+	return text
+}
+
+// classify 根据欢迎信息识别设备类型
+func classify(banner string) string {
+	lower := strings.ToLower(banner)
+	for _, signature := range deviceSignatures {
+		for _, keyword := range signature.keywords {
+			if strings.Contains(lower, keyword) {
+				return signature.device
+			}
+		}
+	}
+	return ""
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i TelnetInfo) Metadata() map[string]string {
+	metadata := map[string]string{
+		"telnet_do":     strings.Join(i.Do, ","),
+		"telnet_will":   strings.Join(i.Will, ","),
+		"telnet_banner": strings.ReplaceAll(i.Banner, "\n", " | "),
+		"telnet_prompt": i.Prompt,
+	}
+	if i.Device != "" {
+		metadata["telnet_device"] = i.Device
+	}
+	return metadata
+}
@@ -2,48 +2,46 @@ package telnet
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
 	"net"
 	"time"
 )
 
 type TelnetHelper struct {
 	net.Conn
-	r       *bufio.Reader
-	version string
+	r           *bufio.Reader
+	readTimeout time.Duration
+	version     string
+	do          []byte // 服务端发送 DO 请求客户端启用的选项
+	will        []byte // 服务端发送 WILL 声明自己启用的选项
+	negotiated  bool   // 是否收到过选项协商
 }
 
-func NewTelnetHelper(network, addr string, timeout time.Duration) (*TelnetHelper, error) {
-	conn, err := net.DialTimeout(network, addr, timeout)
+func NewTelnetHelper(network, addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*TelnetHelper, error) {
+	conn, err := dialer.DialTimeout(dial, network, addr, timeouts.Dial)
 	if err != nil {
 		return nil, err
 	}
 	tel := TelnetHelper{
-		Conn:    conn,
-		r:       bufio.NewReaderSize(conn, 256),
-		version: "v0.1",
+		Conn:        conn,
+		r:           bufio.NewReaderSize(conn, 256),
+		readTimeout: timeouts.Read,
+		version:     "v0.1",
 	}
 	return &tel, nil
 }
 
+// Check 读取欢迎信息并确认是 Telnet，返回读取到的数据长度（不含选项协商）
+// 需要收到 IAC 选项协商或者登录提示，SSH、FTP、SMTP 等其它协议的欢迎信息返回 ErrNotTelnet
 func (t *TelnetHelper) Check() (int, error) {
-	// Read 2 bytes is enough to check
-	buf := make([]byte, 2)
-	var n int
-	for n < len(buf) {
-		b, retry, err := t.tryReadByte()
-		if err != nil {
-			return n, err
-		}
-		if !retry {
-			buf[n] = b
-			n++
-		}
-		if n > 0 && t.r.Buffered() == 0 {
-			// Don't block if can't return more data.
-			return n, err
-		}
+	text, err := t.read()
+	if err != nil {
+		return len(text), err
 	}
-	return n, nil
+	return len(text), t.verify(text)
 }
 
 func (t *TelnetHelper) tryReadByte() (b byte, retry bool, err error) {
@@ -55,24 +53,72 @@ func (t *TelnetHelper) tryReadByte() (b byte, retry bool, err error) {
 	if err != nil {
 		return
 	}
-	if b != cmdIAC {
+	switch b {
+	case cmdIAC:
+		// IAC IAC 是数据中的 255
+		return
+	case cmdWill, cmdWont, cmdDo, cmdDont:
+		t.negotiated = true
 		// Read an option
 		o, err2 := t.r.ReadByte()
 		if err2 != nil {
 			err = err2
 			return
 		}
+		t.record(b, o)
 		// Deny any other option
 		err = t.deny(b, o)
 		if err != nil {
 			return
 		}
-
-		retry = true
+	case cmdSB:
+		t.negotiated = true
+		// 没有同意任何选项，子协商的内容直接跳过
+		err = t.skipSubnegotiation()
+		if err != nil {
+			return
+		}
 	}
+	// 其余是 NOP、GA 等没有选项的命令
+	retry = true
 	return
 }
 
+// record 记录服务端请求的选项，同一个选项只记录一次
+func (t *TelnetHelper) record(cmd, opt byte) {
+	switch cmd {
+	case cmdDo:
+		if bytes.IndexByte(t.do, opt) < 0 {
+			t.do = append(t.do, opt)
+		}
+	case cmdWill:
+		if bytes.IndexByte(t.will, opt) < 0 {
+			t.will = append(t.will, opt)
+		}
+	}
+}
+
+// skipSubnegotiation 跳过 IAC SB ... IAC SE
+func (t *TelnetHelper) skipSubnegotiation() error {
+	for i := 0; i < maxSubnegotiationLen; i++ {
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != cmdIAC {
+			continue
+		}
+		b, err = t.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == cmdSE {
+			return nil
+		}
+	}
+	return errSubnegotiationTooLong
+}
+
 func (t *TelnetHelper) deny(cmd, opt byte) (err error) {
 	switch cmd {
 	case cmdDo:
@@ -96,10 +142,25 @@ func (t *TelnetHelper) dont(option byte) error {
 }
 
 const (
+	cmdSE   = 240
+	cmdSB   = 250
 	cmdWill = 251
 	cmdWont = 252
 	cmdDo   = 253
 	cmdDont = 254
 
 	cmdIAC = 255
+
+	maxSubnegotiationLen = 1024
 )
+
+var errSubnegotiationTooLong = errors.New("telnet subnegotiation too long")
+
+// DefaultPorts 返回 TELNET 协议的常用端口
+func DefaultPorts() []int {
+	return []int{23, 2323}
+}
+
+func (t *TelnetHelper) GetDefaultPorts() []int {
+	return DefaultPorts()
+}
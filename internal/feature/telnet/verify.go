@@ -0,0 +1,67 @@
+package telnet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"regexp"
+)
+
+// otherProtocolProbeLen 没有换行的二进制欢迎信息（例如 MySQL）读取到这个长度时进行识别
+const otherProtocolProbeLen = 16
+
+// ErrNotTelnet 服务端没有进行选项协商也没有登录提示，或者发送的是其它协议的欢迎信息
+var ErrNotTelnet = errors.New("server is not telnet")
+
+// replyCodePattern FTP、SMTP 等协议的三位数字应答码
+var replyCodePattern = regexp.MustCompile(`^[1-5][0-9]{2}[ -]`)
+
+// otherProtocols 其它协议欢迎信息的前缀
+var otherProtocols = []struct {
+	name   string
+	prefix []byte
+}{
+	{"ssh", []byte("SSH-")},
+	{"http", []byte("HTTP/")},
+	{"vnc", []byte("RFB ")},
+	{"pop3", []byte("+OK")},
+	{"imap", []byte("* OK")},
+	{"imap", []byte("* PREAUTH")},
+}
+
+// otherProtocol 返回欢迎信息对应的其它协议，无法识别时返回空
+func otherProtocol(text []byte) string {
+	for _, protocol := range otherProtocols {
+		if bytes.HasPrefix(text, protocol.prefix) {
+			return protocol.name
+		}
+	}
+	if replyCodePattern.Match(text) {
+		// FTP 和 SMTP 的欢迎信息都是 220
+		return "ftp/smtp"
+	}
+	if isMySQLHandshake(text) {
+		return "mysql"
+	}
+	return ""
+}
+
+// isMySQLHandshake MySQL 初始握手包：3 字节小端长度、序号 0、协议版本 10
+func isMySQLHandshake(text []byte) bool {
+	if len(text) < 5 || text[3] != 0 || text[4] != 10 {
+		return false
+	}
+	length := binary.LittleEndian.Uint32(append(text[:3:3], 0))
+	return length > 0 && length < 1024
+}
+
+// verify 没有其它协议的特征，并且收到过选项协商或者最后一行是登录提示时认为是 Telnet
+func (t *TelnetHelper) verify(text []byte) error {
+	if otherProtocol(text) != "" {
+		return ErrNotTelnet
+	}
+	if t.negotiated || promptPattern.Match(bytes.TrimRight(lastLine(text), "\r\n\x00")) {
+		return nil
+	}
+	return ErrNotTelnet
+}
@@ -0,0 +1,126 @@
+package tls
+
+import (
+	"fmt"
+	"strings"
+)
+
+// protocolVersion 枚举的协议版本，SSL 3.0、TLS 1.0、TLS 1.1 视为弱版本
+type protocolVersion struct {
+	id   uint16
+	name string
+	weak bool
+}
+
+var protocolVersions = []protocolVersion{
+	{0x0300, "SSL 3.0", true},
+	{0x0301, "TLS 1.0", true},
+	{0x0302, "TLS 1.1", true},
+	{0x0303, "TLS 1.2", false},
+	{0x0304, "TLS 1.3", false},
+}
+
+// cipherSuite IANA 登记的加密套件编号和名称
+type cipherSuite struct {
+	id   uint16
+	name string
+}
+
+// tls13CipherSuites TLS 1.3 的加密套件，都是前向安全的 AEAD
+var tls13CipherSuites = []cipherSuite{
+	{0x1301, "TLS_AES_128_GCM_SHA256"},
+	{0x1302, "TLS_AES_256_GCM_SHA384"},
+	{0x1303, "TLS_CHACHA20_POLY1305_SHA256"},
+	{0x1304, "TLS_AES_128_CCM_SHA256"},
+	{0x1305, "TLS_AES_128_CCM_8_SHA256"},
+}
+
+// legacyCipherSuites SSL 3.0 到 TLS 1.2 的加密套件，包括 crypto/tls 不支持的 NULL、EXPORT、DES 等弱套件
+var legacyCipherSuites = []cipherSuite{
+	{0xc02c, "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+	{0xc030, "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+	{0xcca9, "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+	{0xcca8, "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+	{0xc02b, "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	{0xc02f, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	{0x009f, "TLS_DHE_RSA_WITH_AES_256_GCM_SHA384"},
+	{0xccaa, "TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+	{0x009e, "TLS_DHE_RSA_WITH_AES_128_GCM_SHA256"},
+	{0xc024, "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384"},
+	{0xc028, "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384"},
+	{0xc023, "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256"},
+	{0xc027, "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256"},
+	{0x006b, "TLS_DHE_RSA_WITH_AES_256_CBC_SHA256"},
+	{0x0067, "TLS_DHE_RSA_WITH_AES_128_CBC_SHA256"},
+	{0xc00a, "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA"},
+	{0xc014, "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA"},
+	{0xc009, "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA"},
+	{0xc013, "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA"},
+	{0x0039, "TLS_DHE_RSA_WITH_AES_256_CBC_SHA"},
+	{0x0033, "TLS_DHE_RSA_WITH_AES_128_CBC_SHA"},
+	{0x009d, "TLS_RSA_WITH_AES_256_GCM_SHA384"},
+	{0x009c, "TLS_RSA_WITH_AES_128_GCM_SHA256"},
+	{0x003d, "TLS_RSA_WITH_AES_256_CBC_SHA256"},
+	{0x003c, "TLS_RSA_WITH_AES_128_CBC_SHA256"},
+	{0x0035, "TLS_RSA_WITH_AES_256_CBC_SHA"},
+	{0x002f, "TLS_RSA_WITH_AES_128_CBC_SHA"},
+	{0x0084, "TLS_RSA_WITH_CAMELLIA_256_CBC_SHA"},
+	{0x0041, "TLS_RSA_WITH_CAMELLIA_128_CBC_SHA"},
+	{0xc008, "TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA"},
+	{0xc012, "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA"},
+	{0x0016, "TLS_DHE_RSA_WITH_3DES_EDE_CBC_SHA"},
+	{0x000a, "TLS_RSA_WITH_3DES_EDE_CBC_SHA"},
+	{0xc007, "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA"},
+	{0xc011, "TLS_ECDHE_RSA_WITH_RC4_128_SHA"},
+	{0x0005, "TLS_RSA_WITH_RC4_128_SHA"},
+	{0x0004, "TLS_RSA_WITH_RC4_128_MD5"},
+	{0x0015, "TLS_DHE_RSA_WITH_DES_CBC_SHA"},
+	{0x0009, "TLS_RSA_WITH_DES_CBC_SHA"},
+	{0x0014, "TLS_DHE_RSA_EXPORT_WITH_DES40_CBC_SHA"},
+	{0x0008, "TLS_RSA_EXPORT_WITH_DES40_CBC_SHA"},
+	{0x0006, "TLS_RSA_EXPORT_WITH_RC2_CBC_40_MD5"},
+	{0x0003, "TLS_RSA_EXPORT_WITH_RC4_40_MD5"},
+	{0x003a, "TLS_DH_anon_WITH_AES_256_CBC_SHA"},
+	{0x0034, "TLS_DH_anon_WITH_AES_128_CBC_SHA"},
+	{0x001b, "TLS_DH_anon_WITH_3DES_EDE_CBC_SHA"},
+	{0x0018, "TLS_DH_anon_WITH_RC4_128_MD5"},
+	{0x003b, "TLS_RSA_WITH_NULL_SHA256"},
+	{0x0002, "TLS_RSA_WITH_NULL_SHA"},
+	{0x0001, "TLS_RSA_WITH_NULL_MD5"},
+}
+
+// weakCipherKeywords 名称中包含这些关键字的套件是弱套件：无加密、出口级、匿名、RC4、DES/3DES、RC2、MD5
+var weakCipherKeywords = []string{"_NULL_", "_EXPORT_", "_anon_", "_RC4_", "DES", "_RC2_", "_MD5"}
+
+// isWeakCipher 弱套件或者没有前向安全（RSA 密钥交换）的套件
+func isWeakCipher(name string) bool {
+	if strings.HasPrefix(name, "TLS_RSA_") {
+		return true
+	}
+	for _, keyword := range weakCipherKeywords {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// cipherSuitesFor 返回某个版本可以提供的加密套件
+func cipherSuitesFor(version uint16) []cipherSuite {
+	if version == 0x0304 {
+		return tls13CipherSuites
+	}
+	return legacyCipherSuites
+}
+
+// cipherSuiteName 返回加密套件的名称，不在列表中时返回编号
+func cipherSuiteName(id uint16) string {
+	for _, suites := range [][]cipherSuite{tls13CipherSuites, legacyCipherSuites} {
+		for _, suite := range suites {
+			if suite.id == id {
+				return suite.name
+			}
+		}
+	}
+	return fmt.Sprintf("0x%04x", id)
+}
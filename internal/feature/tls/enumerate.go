@@ -0,0 +1,358 @@
+package tls
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+const (
+	GradeStrong = "strong" // 只接受 TLS 1.2 以上版本和前向安全的加密套件
+	GradeWeak   = "weak"   // 接受弱版本或者弱加密套件
+
+	recordTypeAlert     = 21
+	recordTypeHandshake = 22
+
+	handshakeTypeClientHello = 1
+	handshakeTypeServerHello = 2
+
+	extensionServerName          = 0
+	extensionSupportedGroups     = 10
+	extensionECPointFormats      = 11
+	extensionSignatureAlgorithms = 13
+	extensionSupportedVersions   = 43
+	extensionPSKModes            = 45
+	extensionKeyShare            = 51
+	extensionRenegotiationInfo   = 0xff01
+
+	groupX25519 = 29
+
+	maxServerHelloLen = 64 * 1024
+)
+
+// ErrNoTLSVersion 所有版本的握手都被拒绝
+var ErrNoTLSVersion = errors.New("no tls version accepted")
+
+var (
+	supportedGroups     = []uint16{groupX25519, 23, 24, 25, 256, 257}
+	signatureAlgorithms = []uint16{0x0403, 0x0503, 0x0603, 0x0804, 0x0805, 0x0806, 0x0807, 0x0401, 0x0501, 0x0601, 0x0203, 0x0201}
+)
+
+// CipherResult 服务端接受的加密套件
+type CipherResult struct {
+	ID   uint16 `json:"id"`
+	Name string `json:"name"`
+	Weak bool   `json:"weak"`
+}
+
+// VersionResult 服务端接受的协议版本及该版本下接受的加密套件，顺序为服务端的选择顺序
+type VersionResult struct {
+	Version string         `json:"version"`
+	Weak    bool           `json:"weak"`
+	Ciphers []CipherResult `json:"ciphers"`
+}
+
+// TLSEnumeration TLS 版本和加密套件枚举结果
+type TLSEnumeration struct {
+	Versions     []VersionResult `json:"versions"`      // 接受的版本，从低到高
+	WeakVersions []string        `json:"weak_versions"` // 接受的弱版本
+	WeakCiphers  []string        `json:"weak_ciphers"`  // 接受的弱加密套件，不重复
+	Grade        string          `json:"grade"`         // strong 或 weak
+}
+
+// Enumerate 对 SSL 3.0 到 TLS 1.3 的每个版本反复握手：每次提供尚未被选中的全部加密套件，
+// 记录服务端选择的套件后将其移除，直到服务端拒绝，得到该版本接受的全部套件
+// 只发送 ClientHello 并读取 ServerHello，不完成握手，因此可以检测 crypto/tls 不支持的版本和套件
+// 每次握手前调用 wait，用于接入扫描的速率限制，wait 为 nil 时不限制；wait 返回错误时结束整个枚举
+func (t TLSHelper) Enumerate(addr, serverName string, timeouts common.Timeouts, dial dialer.DialContextFunc, wait func() error) (*TLSEnumeration, error) {
+	if net.ParseIP(serverName) != nil {
+		serverName = ""
+	}
+	result := &TLSEnumeration{
+		Versions:     make([]VersionResult, 0),
+		WeakVersions: make([]string, 0),
+		WeakCiphers:  make([]string, 0),
+		Grade:        GradeStrong,
+	}
+	weakCiphers := make(map[string]bool)
+	for _, version := range protocolVersions {
+		candidates := make([]uint16, 0)
+		for _, suite := range cipherSuitesFor(version.id) {
+			candidates = append(candidates, suite.id)
+		}
+
+		versionResult := VersionResult{Version: version.name, Weak: version.weak, Ciphers: make([]CipherResult, 0)}
+		for len(candidates) > 0 {
+			if wait != nil {
+				if err := wait(); err != nil {
+					return nil, err
+				}
+			}
+			selected, accepted, err := t.offer(addr, serverName, version.id, candidates, timeouts, dial)
+			if err != nil || !accepted {
+				// 建立连接失败只结束该版本的枚举，例如服务端限制了连接频率，其它版本继续枚举
+				break
+			}
+			index := indexOf(candidates, selected)
+			if index < 0 {
+				// 服务端选择了没有提供的套件，不符合协议，停止该版本的枚举
+				break
+			}
+			candidates = append(candidates[:index], candidates[index+1:]...)
+			name := cipherSuiteName(selected)
+			weak := isWeakCipher(name)
+			versionResult.Ciphers = append(versionResult.Ciphers, CipherResult{ID: selected, Name: name, Weak: weak})
+			if weak && !weakCiphers[name] {
+				weakCiphers[name] = true
+				result.WeakCiphers = append(result.WeakCiphers, name)
+			}
+		}
+		if len(versionResult.Ciphers) == 0 {
+			continue
+		}
+		result.Versions = append(result.Versions, versionResult)
+		if version.weak {
+			result.WeakVersions = append(result.WeakVersions, version.name)
+		}
+	}
+
+	if len(result.Versions) == 0 {
+		return nil, ErrNoTLSVersion
+	}
+	if len(result.WeakVersions) > 0 || len(result.WeakCiphers) > 0 {
+		result.Grade = GradeWeak
+	}
+	return result, nil
+}
+
+func indexOf(ids []uint16, id uint16) int {
+	for i, value := range ids {
+		if value == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// offer 发送只包含 version 和 cipherSuites 的 ClientHello，返回服务端选择的套件
+// 服务端返回 alert、其它版本或者断开连接时 accepted 为 false；只有建立连接失败才返回 err
+func (t TLSHelper) offer(addr, serverName string, version uint16, cipherSuites []uint16, timeouts common.Timeouts, dial dialer.DialContextFunc) (uint16, bool, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	hello, err := clientHello(serverName, version, cipherSuites)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		return 0, false, nil
+	}
+	if _, err := conn.Write(hello); err != nil {
+		return 0, false, nil
+	}
+	selectedVersion, cipherSuite, err := readServerHello(conn)
+	if err != nil || selectedVersion != version {
+		return 0, false, nil
+	}
+	return cipherSuite, true, nil
+}
+
+// clientHello 构造 ClientHello 记录，TLS 1.3 通过 supported_versions 协商，并附带 X25519 key_share
+func clientHello(serverName string, version uint16, cipherSuites []uint16) ([]byte, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	sessionID := make([]byte, 0)
+	legacyVersion := version
+	var keyShare []byte
+	if version == 0x0304 {
+		legacyVersion = 0x0303
+		// 兼容模式要求 32 字节的 session id
+		sessionID = make([]byte, 32)
+		if _, err := rand.Read(sessionID); err != nil {
+			return nil, err
+		}
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		keyShare = key.PublicKey().Bytes()
+	}
+
+	var body cryptobyte.Builder
+	body.AddUint16(legacyVersion)
+	body.AddBytes(random)
+	body.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sessionID) })
+	body.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, suite := range cipherSuites {
+			b.AddUint16(suite)
+		}
+	})
+	// 只提供 null 压缩
+	body.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(0) })
+	if version != 0x0300 {
+		// SSL 3.0 没有扩展
+		body.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			addExtensions(b, serverName, version, keyShare)
+		})
+	}
+
+	var handshake cryptobyte.Builder
+	handshake.AddUint8(handshakeTypeClientHello)
+	handshake.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(body.BytesOrPanic()) })
+
+	recordVersion := uint16(0x0301)
+	if version == 0x0300 {
+		recordVersion = 0x0300
+	}
+	var record cryptobyte.Builder
+	record.AddUint8(recordTypeHandshake)
+	record.AddUint16(recordVersion)
+	record.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(handshake.BytesOrPanic()) })
+	return record.Bytes()
+}
+
+func addExtensions(b *cryptobyte.Builder, serverName string, version uint16, keyShare []byte) {
+	if serverName != "" {
+		addExtension(b, extensionServerName, func(b *cryptobyte.Builder) {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint8(0) // host_name
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte(serverName)) })
+			})
+		})
+	}
+	addExtension(b, extensionSupportedGroups, func(b *cryptobyte.Builder) {
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, group := range supportedGroups {
+				b.AddUint16(group)
+			}
+		})
+	})
+	addExtension(b, extensionECPointFormats, func(b *cryptobyte.Builder) {
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(0) })
+	})
+	addExtension(b, extensionSignatureAlgorithms, func(b *cryptobyte.Builder) {
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, algorithm := range signatureAlgorithms {
+				b.AddUint16(algorithm)
+			}
+		})
+	})
+	addExtension(b, extensionRenegotiationInfo, func(b *cryptobyte.Builder) { b.AddUint8(0) })
+	if version != 0x0304 {
+		return
+	}
+	addExtension(b, extensionSupportedVersions, func(b *cryptobyte.Builder) {
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint16(0x0304) })
+	})
+	addExtension(b, extensionPSKModes, func(b *cryptobyte.Builder) {
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(1) }) // psk_dhe_ke
+	})
+	addExtension(b, extensionKeyShare, func(b *cryptobyte.Builder) {
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(groupX25519)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(keyShare) })
+		})
+	})
+}
+
+func addExtension(b *cryptobyte.Builder, extension uint16, data func(b *cryptobyte.Builder)) {
+	b.AddUint16(extension)
+	b.AddUint16LengthPrefixed(data)
+}
+
+// readServerHello 读取服务端的 ServerHello，返回协商的版本（TLS 1.3 取 supported_versions）和加密套件
+// ServerHello 可能被拆分到多个记录中；HelloRetryRequest 同样包含服务端选择的套件
+func readServerHello(conn io.Reader) (uint16, uint16, error) {
+	handshake := make([]byte, 0)
+	for {
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return 0, 0, err
+		}
+		length := int(header[3])<<8 | int(header[4])
+		if header[0] == recordTypeAlert {
+			return 0, 0, errors.New("server sent alert")
+		}
+		if header[0] != recordTypeHandshake || len(handshake)+length > maxServerHelloLen {
+			return 0, 0, fmt.Errorf("unexpected tls record type %d", header[0])
+		}
+		fragment := make([]byte, length)
+		if _, err := io.ReadFull(conn, fragment); err != nil {
+			return 0, 0, err
+		}
+		handshake = append(handshake, fragment...)
+		if len(handshake) < 4 {
+			continue
+		}
+		messageLength := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+		if len(handshake) >= 4+messageLength {
+			if handshake[0] != handshakeTypeServerHello {
+				return 0, 0, fmt.Errorf("unexpected handshake message %d", handshake[0])
+			}
+			return parseServerHello(handshake[4 : 4+messageLength])
+		}
+	}
+}
+
+func parseServerHello(message []byte) (uint16, uint16, error) {
+	s := cryptobyte.String(message)
+	var version, cipherSuite uint16
+	var random, sessionID []byte
+	var compression uint8
+	if !s.ReadUint16(&version) || !s.ReadBytes(&random, 32) ||
+		!s.ReadUint8LengthPrefixed((*cryptobyte.String)(&sessionID)) ||
+		!s.ReadUint16(&cipherSuite) || !s.ReadUint8(&compression) {
+		return 0, 0, errors.New("malformed server hello")
+	}
+	var extensions cryptobyte.String
+	if s.Empty() || !s.ReadUint16LengthPrefixed(&extensions) {
+		return version, cipherSuite, nil
+	}
+	for !extensions.Empty() {
+		var extension uint16
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&extension) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return 0, 0, errors.New("malformed server hello extensions")
+		}
+		if extension == extensionSupportedVersions {
+			if !data.ReadUint16(&version) {
+				return 0, 0, errors.New("malformed supported_versions")
+			}
+		}
+	}
+	return version, cipherSuite, nil
+}
+
+// Metadata 转换为扫描结果的附加信息，每个版本接受的套件记录在 tls_ciphers_<版本> 中，例如 tls_ciphers_tls1_2
+func (e TLSEnumeration) Metadata() map[string]string {
+	versions := make([]string, 0, len(e.Versions))
+	metadata := map[string]string{
+		"tls_grade":         e.Grade,
+		"tls_weak_versions": strings.Join(e.WeakVersions, ","),
+		"tls_weak_ciphers":  strings.Join(e.WeakCiphers, ","),
+	}
+	for _, version := range e.Versions {
+		versions = append(versions, version.Version)
+		names := make([]string, 0, len(version.Ciphers))
+		for _, cipher := range version.Ciphers {
+			names = append(names, cipher.Name)
+		}
+		key := strings.NewReplacer(" ", "", ".", "_").Replace(strings.ToLower(version.Version))
+		metadata["tls_ciphers_"+key] = strings.Join(names, ",")
+	}
+	metadata["tls_versions"] = strings.Join(versions, ",")
+	return metadata
+}
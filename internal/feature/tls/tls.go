@@ -0,0 +1,103 @@
+package tls
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/allanpk716/go-protocol-detector/internal/common"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	"github.com/allanpk716/go-protocol-detector/internal/utils"
+)
+
+// alpnProtocols 握手时提供的 ALPN，用来判断服务端是否支持 HTTP/2
+var alpnProtocols = []string{"h2", "http/1.1"}
+
+type TLSHelper struct {
+	version string
+}
+
+func NewTLSHelper() *TLSHelper {
+	return &TLSHelper{version: "v0.1"}
+}
+
+func (t TLSHelper) GetVersion() string {
+	return t.version
+}
+
+// DefaultPorts 返回常见的 TLS 端口：HTTPS、SMTPS、LDAPS、DoT、FTPS、IMAPS、POP3S
+func DefaultPorts() []int {
+	return []int{443, 465, 636, 853, 990, 993, 995, 8443}
+}
+
+func (t TLSHelper) GetDefaultPorts() []int {
+	return DefaultPorts()
+}
+
+// TLSInfo TLS 握手结果以及 SNI 行为
+type TLSInfo struct {
+	utils.TLSInfo
+	SNITested      bool `json:"sni_tested"`       // 是否比较了发送和不发送 SNI 的握手结果
+	SNIRequired    bool `json:"sni_required"`     // 不发送 SNI 时握手失败
+	SNICertDiffers bool `json:"sni_cert_differs"` // 发送 SNI 时返回了不同的证书，常见于一个端口承载多个站点
+	SNIRejected    bool `json:"sni_rejected"`     // 发送 SNI 时握手失败，不发送时成功，服务端拒绝了这个域名
+}
+
+// Check 不发送 SNI 完成一次握手；serverName 是域名时再发送 SNI 握手，比较两次的结果
+// 返回的证书信息优先使用发送 SNI 的结果
+func (t TLSHelper) Check(addr, serverName string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*TLSInfo, error) {
+	plain, plainErr := t.handshake(addr, "", timeouts, dial)
+	if serverName == "" || net.ParseIP(serverName) != nil {
+		if plainErr != nil {
+			return nil, plainErr
+		}
+		return &TLSInfo{TLSInfo: *plain}, nil
+	}
+
+	withSNI, err := t.handshake(addr, serverName, timeouts, dial)
+	if err != nil {
+		if plainErr != nil {
+			return nil, err
+		}
+		// 服务端拒绝这个 SNI，仍然是 TLS 服务
+		return &TLSInfo{TLSInfo: *plain, SNITested: true, SNIRejected: true}, nil
+	}
+	info := &TLSInfo{TLSInfo: *withSNI, SNITested: true, SNIRequired: plainErr != nil}
+	if plainErr == nil && plain.Leaf() != nil && withSNI.Leaf() != nil {
+		info.SNICertDiffers = plain.Leaf().SHA256 != withSNI.Leaf().SHA256
+	}
+	return info, nil
+}
+
+// handshake 建立新连接完成一次 TLS 握手，serverName 为空时不发送 SNI
+func (t TLSHelper) handshake(addr, serverName string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*utils.TLSInfo, error) {
+	conn, err := dialer.DialTimeout(dial, "tcp", addr, timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(common.Deadline(timeouts.Handshake)); err != nil {
+		return nil, err
+	}
+	config := utils.InsecureTLSConfig(serverName)
+	config.NextProtos = alpnProtocols
+	tlsConn, info, err := utils.TLSClient(conn, config)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn.Close()
+	return info, nil
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i TLSInfo) Metadata() map[string]string {
+	metadata := i.TLSInfo.Metadata("tls_")
+	if i.SNITested {
+		metadata["tls_sni_required"] = fmt.Sprintf("%v", i.SNIRequired)
+		metadata["tls_sni_cert_differs"] = fmt.Sprintf("%v", i.SNICertDiffers)
+	}
+	if i.SNIRejected {
+		metadata["tls_sni_rejected"] = "true"
+	}
+	return metadata
+}
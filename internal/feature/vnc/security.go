@@ -0,0 +1,185 @@
+package vnc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxReasonLen 服务端拒绝连接时原因字符串的最大长度，避免异常数据导致大量分配
+const maxReasonLen = 4096
+
+const (
+	SecurityTypeInvalid = 0
+	SecurityTypeNone    = 1
+	SecurityTypeVNCAuth = 2
+)
+
+// securityTypeNames RFB 安全类型编号对应的名称（RFC 6143 7.1.2 以及 IANA RFB Security Types 登记）
+var securityTypeNames = map[uint8]string{
+	0:   "Invalid",
+	1:   "None",
+	2:   "VNC Authentication",
+	5:   "RA2",
+	6:   "RA2ne",
+	16:  "Tight",
+	17:  "Ultra",
+	18:  "TLS",
+	19:  "VeNCrypt",
+	20:  "GTK-VNC SASL",
+	21:  "MD5 hash",
+	22:  "Colin Dean xvp",
+	30:  "Apple Remote Desktop",
+	35:  "Apple Remote Desktop (session)",
+	113: "UltraVNC MS-Logon II",
+	129: "Tight Unix Login",
+}
+
+// SecurityTypeName 返回安全类型的名称，未登记的类型返回 Unknown(编号)
+func SecurityTypeName(securityType uint8) string {
+	if name, ok := securityTypeNames[securityType]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", securityType)
+}
+
+// VNCInfo 服务端的 RFB 协议版本以及支持的安全类型
+type VNCInfo struct {
+	ServerVersion string   `json:"server_version"` // 服务端 ProtocolVersion 中的版本，例如 003.008
+	ClientVersion string   `json:"client_version"` // 回复给服务端的版本
+	SecurityTypes []uint8  `json:"security_types"` // 服务端提供的安全类型编号
+	SecurityNames []string `json:"security_names"` // 安全类型的名称
+	NoAuth        bool     `json:"no_auth"`        // 服务端允许 None，不需要密码即可连接
+	FailureReason string   `json:"failure_reason"` // 服务端拒绝连接时给出的原因，例如连接次数过多
+}
+
+// parseProtocolVersion 解析 "003.008" 形式的版本号
+func parseProtocolVersion(version string) (int, int, bool) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 || len(parts[0]) != 3 || len(parts[1]) != 3 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// clientVersion 选择回复给服务端的版本：客户端只实现 3.3、3.7、3.8，
+// 高于 3.8 的版本（例如 Apple 的 003.889、RealVNC 的 004.001）回复 3.8，3.7 以下按 3.3 处理
+func clientVersion(major, minor int) string {
+	switch {
+	case major > 3 || major == 3 && minor >= 8:
+		return "003.008"
+	case major == 3 && minor == 7:
+		return "003.007"
+	default:
+		return "003.003"
+	}
+}
+
+// SecurityTypes 读取 ProtocolVersion，回复匹配的版本后读取服务端提供的安全类型，不会进行认证
+// ProtocolVersion 合法时即使后续步骤失败也会返回已读取的信息
+func (v VNCHelper) SecurityTypes() (*VNCInfo, error) {
+	if v.readTimeout > 0 {
+		if err := v.Conn.SetDeadline(time.Now().Add(v.readTimeout)); err != nil {
+			return nil, err
+		}
+	}
+	serverVersion, err := v.readProtocolVersion()
+	if err != nil {
+		return nil, err
+	}
+	major, minor, _ := parseProtocolVersion(serverVersion)
+	info := &VNCInfo{
+		ServerVersion: serverVersion,
+		ClientVersion: clientVersion(major, minor),
+		SecurityTypes: make([]uint8, 0),
+		SecurityNames: make([]string, 0),
+	}
+
+	if _, err := v.Conn.Write([]byte("RFB " + info.ClientVersion + "\n")); err != nil {
+		return info, err
+	}
+
+	if info.ClientVersion == "003.003" {
+		// 3.3 由服务端决定唯一的安全类型，以 uint32 发送
+		var securityType uint32
+		if err := binary.Read(v.Conn, binary.BigEndian, &securityType); err != nil {
+			return info, err
+		}
+		if securityType == SecurityTypeInvalid {
+			info.FailureReason, err = v.readReason()
+			return info, err
+		}
+		if securityType > 255 {
+			return info, fmt.Errorf("invalid security type %d", securityType)
+		}
+		info.addSecurityType(uint8(securityType))
+		return info, nil
+	}
+
+	// 3.7、3.8 先发送安全类型数量，为 0 时表示拒绝连接，后面是原因
+	var count [1]byte
+	if _, err := io.ReadFull(v.Conn, count[:]); err != nil {
+		return info, err
+	}
+	if count[0] == 0 {
+		info.FailureReason, err = v.readReason()
+		return info, err
+	}
+	securityTypes := make([]byte, count[0])
+	if _, err := io.ReadFull(v.Conn, securityTypes); err != nil {
+		return info, err
+	}
+	for _, securityType := range securityTypes {
+		info.addSecurityType(securityType)
+	}
+	return info, nil
+}
+
+func (i *VNCInfo) addSecurityType(securityType uint8) {
+	i.SecurityTypes = append(i.SecurityTypes, securityType)
+	i.SecurityNames = append(i.SecurityNames, SecurityTypeName(securityType))
+	if securityType == SecurityTypeNone {
+		i.NoAuth = true
+	}
+}
+
+// readReason 读取 uint32 长度 + 内容的原因字符串
+func (v VNCHelper) readReason() (string, error) {
+	var length uint32
+	if err := binary.Read(v.Conn, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	if length > maxReasonLen {
+		return "", errors.New("vnc failure reason too long")
+	}
+	reason := make([]byte, length)
+	if _, err := io.ReadFull(v.Conn, reason); err != nil {
+		return "", err
+	}
+	return string(reason), nil
+}
+
+// Metadata 转换为扫描结果的附加信息
+func (i VNCInfo) Metadata() map[string]string {
+	metadata := map[string]string{
+		"vnc_version":        i.ServerVersion,
+		"vnc_security_types": strings.Join(i.SecurityNames, ","),
+		"vnc_no_auth":        fmt.Sprintf("%v", i.NoAuth),
+	}
+	if i.FailureReason != "" {
+		metadata["vnc_failure_reason"] = i.FailureReason
+	}
+	return metadata
+}
@@ -4,30 +4,43 @@ import (
 	"bytes"
 	"github.com/allanpk716/go-protocol-detector/internal/common"
 	"github.com/allanpk716/go-protocol-detector/internal/custom_error"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	"io"
 	"net"
 	"time"
 )
 
+// protocolVersionLen ProtocolVersion 固定为 12 字节，例如 "RFB 003.008\n"
+const protocolVersionLen = 12
+
 type VNCHelper struct {
 	net.Conn
 	ReceiverFeatures []common.ReceiverFeature
-	timeout          time.Duration
+	readTimeout      time.Duration
 	version          string
 }
 
-func NewVNCHelper(network, addr string, timeout time.Duration) (*VNCHelper, error) {
-	conn, err := net.DialTimeout(network, addr, timeout)
+func NewVNCHelper(network, addr string, timeouts common.Timeouts, dial dialer.DialContextFunc) (*VNCHelper, error) {
+	conn, err := dialer.DialTimeout(dial, network, addr, timeouts.Dial)
 	if err != nil {
 		return nil, err
 	}
 	vnc := VNCHelper{
-		Conn:    conn,
-		timeout: timeout,
+		Conn:        conn,
+		readTimeout: timeouts.Read,
 		ReceiverFeatures: []common.ReceiverFeature{
 			{
 				StartIndex:   0,
 				FeatureBytes: []byte("RFB "),
 			},
+			{
+				StartIndex:   7,
+				FeatureBytes: []byte("."),
+			},
+			{
+				StartIndex:   11,
+				FeatureBytes: []byte("\n"),
+			},
 		},
 		version: "v0.1",
 	}
@@ -38,20 +51,42 @@ func (v VNCHelper) GetVersion() string {
 	return v.version
 }
 
+// DefaultPorts 返回 VNC 协议的常用端口
+func DefaultPorts() []int {
+	return []int{5900, 5901, 5902, 5903, 5904, 5905, 5906, 5907, 5908, 5909, 5910}
+}
+
+func (v VNCHelper) GetDefaultPorts() []int {
+	return DefaultPorts()
+}
+
 func (v VNCHelper) Check() error {
 
-	err := v.Conn.SetReadDeadline(time.Now().Add(v.timeout))
-	if err != nil {
-		return custom_error.ErrVNCNotFound
+	if v.readTimeout > 0 {
+		if err := v.Conn.SetReadDeadline(time.Now().Add(v.readTimeout)); err != nil {
+			return custom_error.ErrVNCNotFound
+		}
 	}
-	feature := v.ReceiverFeatures[0]
-	var readBuf = make([]byte, len(feature.FeatureBytes))
-	_, err = v.Conn.Read(readBuf)
-	if err != nil {
-		return custom_error.ErrVNCNotFound
-	}
-	if bytes.Equal(readBuf[feature.StartIndex:feature.StartIndex+len(feature.FeatureBytes)], feature.FeatureBytes) == false {
+	if _, err := v.readProtocolVersion(); err != nil {
 		return custom_error.ErrVNCNotFound
 	}
 	return nil
 }
+
+// readProtocolVersion 读取完整的 12 字节 ProtocolVersion，返回其中的 ProtocolVersion，例如 003.008
+func (v VNCHelper) readProtocolVersion() (string, error) {
+	var readBuf = make([]byte, protocolVersionLen)
+	if _, err := io.ReadFull(v.Conn, readBuf); err != nil {
+		return "", err
+	}
+	for _, feature := range v.ReceiverFeatures {
+		if bytes.Equal(readBuf[feature.StartIndex:feature.StartIndex+len(feature.FeatureBytes)], feature.FeatureBytes) == false {
+			return "", custom_error.ErrVNCNotFound
+		}
+	}
+	version := string(readBuf[4:11])
+	if _, _, ok := parseProtocolVersion(version); !ok {
+		return "", custom_error.ErrVNCNotFound
+	}
+	return version, nil
+}
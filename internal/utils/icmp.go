@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	icmpTypeEchoReply   = 0
+	icmpTypeEchoRequest = 8
+)
+
+// ICMPEcho 向目标主机发送一个 ICMP Echo 请求并等待应答
+// 需要原始套接字权限（root 或 CAP_NET_RAW），没有权限时直接返回错误，调用方应当降级为 TCP 探测
+// dial 用于指定源地址，为 nil 时由系统选择
+func ICMPEcho(ctx context.Context, host string, dial dialer.DialContextFunc) error {
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() == nil {
+		return fmt.Errorf("icmp echo only supports ipv4 address: %s", host)
+	}
+
+	if dial == nil {
+		dial = dialer.Direct
+	}
+	conn, err := dial(ctx, "ip4:icmp", host)
+	if err != nil {
+		return fmt.Errorf("icmp dial failed: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(2 * time.Second)
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	id := uint16(os.Getpid() & 0xffff)
+	seq := uint16(time.Now().UnixNano() & 0xffff)
+	if _, err = conn.Write(buildEchoRequest(id, seq)); err != nil {
+		return fmt.Errorf("icmp write failed: %w", err)
+	}
+
+	// 原始套接字可能收到其他 ICMP 报文，读到匹配的应答或超时为止
+	reply := make([]byte, 1500)
+	for {
+		n, err := conn.Read(reply)
+		if err != nil {
+			return fmt.Errorf("icmp read failed: %w", err)
+		}
+		replyID, replySeq, ok := ParseICMPEchoReply(reply[:n])
+		if ok && replyID == id && replySeq == seq {
+			return nil
+		}
+	}
+}
+
+// ParseICMPEchoReply 解析 ICMP Echo 应答，返回 id 和 seq，不是 Echo 应答时 ok 为 false
+// Linux 上从 ip4:icmp 连接 Read 到的数据以 IPv4 首部开头，按照首部长度（IHL）跳过
+func ParseICMPEchoReply(packet []byte) (id, seq uint16, ok bool) {
+	if len(packet) > 0 && packet[0]>>4 == 4 {
+		headerLen := int(packet[0]&0x0f) * 4
+		if headerLen < 20 || len(packet) < headerLen {
+			return 0, 0, false
+		}
+		packet = packet[headerLen:]
+	}
+	if len(packet) < 8 || packet[0] != icmpTypeEchoReply {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint16(packet[4:6]), binary.BigEndian.Uint16(packet[6:8]), true
+}
+
+// buildEchoRequest 构造 ICMP Echo 请求报文
+func buildEchoRequest(id, seq uint16) []byte {
+	msg := make([]byte, 16)
+	msg[0] = icmpTypeEchoRequest
+	msg[1] = 0
+	binary.BigEndian.PutUint16(msg[4:6], id)
+	binary.BigEndian.PutUint16(msg[6:8], seq)
+	copy(msg[8:], "go-pd-v1")
+	binary.BigEndian.PutUint16(msg[2:4], icmpChecksum(msg))
+	return msg
+}
+
+// icmpChecksum 计算 ICMP 校验和
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
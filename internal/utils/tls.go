@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// InsecureTLSConfig 返回用于协议探测的 TLS 配置：不校验证书，并且允许旧版本协议和弱加密套件，
+// 以便与 Windows Server 2008 等只支持 TLS 1.0 / RSA 密钥交换的老旧服务端完成握手
+// 这里只做探测和信息收集，不传输敏感数据
+func InsecureTLSConfig(serverName string) *tls.Config {
+	suites := make([]uint16, 0)
+	for _, suite := range tls.CipherSuites() {
+		suites = append(suites, suite.ID)
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		suites = append(suites, suite.ID)
+	}
+
+	return &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		CipherSuites:       suites,
+	}
+}
+
+// CertificateInfo 服务端证书的关键信息，用于证书巡检（即将过期、默认自签名证书等）
+type CertificateInfo struct {
+	SubjectCN  string    `json:"subject_cn"`  // 主题 CN
+	Subject    string    `json:"subject"`     // 主题 DN
+	SANs       []string  `json:"sans"`        // 主题备用名称：DNS、IP、邮箱、URI
+	Issuer     string    `json:"issuer"`      // 颁发者 DN
+	NotBefore  time.Time `json:"not_before"`  // 生效时间
+	NotAfter   time.Time `json:"not_after"`   // 过期时间
+	KeyType    string    `json:"key_type"`    // 公钥类型：RSA、ECDSA、Ed25519
+	KeySize    int       `json:"key_size"`    // 公钥长度（位），ECDSA 为曲线长度
+	SHA256     string    `json:"sha256"`      // DER 的 SHA-256 指纹（小写十六进制）
+	SelfSigned bool      `json:"self_signed"` // 是否自签名
+}
+
+// NewCertificateInfo 从 x509 证书中提取关键信息
+func NewCertificateInfo(cert *x509.Certificate) *CertificateInfo {
+	sum := sha256.Sum256(cert.Raw)
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	keyType, keySize := publicKeyInfo(cert.PublicKey)
+	return &CertificateInfo{
+		SubjectCN:  cert.Subject.CommonName,
+		Subject:    cert.Subject.String(),
+		SANs:       sans,
+		Issuer:     cert.Issuer.String(),
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		KeyType:    keyType,
+		KeySize:    keySize,
+		SHA256:     hex.EncodeToString(sum[:]),
+		SelfSigned: isSelfSigned(cert),
+	}
+}
+
+// publicKeyInfo 返回公钥类型和长度
+func publicKeyInfo(publicKey interface{}) (string, int) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	case *dsa.PublicKey:
+		return "DSA", key.P.BitLen()
+	default:
+		return "unknown", 0
+	}
+}
+
+// isSelfSigned 颁发者与主题相同且能用自身公钥验证签名
+// 不使用 CheckSignatureFrom，因为它要求证书带 CA 标记，而 RDP 等服务自动生成的证书通常没有
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// PeerCertificateInfo 返回 TLS 连接中服务端叶子证书的信息，没有证书时返回 nil
+func PeerCertificateInfo(state tls.ConnectionState) *CertificateInfo {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return NewCertificateInfo(state.PeerCertificates[0])
+}
+
+// Expired 证书在 now 时刻是否已经过期或尚未生效
+func (c CertificateInfo) Expired(now time.Time) bool {
+	return now.After(c.NotAfter) || now.Before(c.NotBefore)
+}
+
+// Metadata 转换为扫描结果的附加信息，prefix 用于区分协议，例如 "rdp_"
+func (c CertificateInfo) Metadata(prefix string) map[string]string {
+	return map[string]string{
+		prefix + "cert_subject_cn":  c.SubjectCN,
+		prefix + "cert_subject":     c.Subject,
+		prefix + "cert_sans":        strings.Join(c.SANs, ","),
+		prefix + "cert_issuer":      c.Issuer,
+		prefix + "cert_key_type":    c.KeyType,
+		prefix + "cert_key_size":    fmt.Sprintf("%d", c.KeySize),
+		prefix + "cert_not_before":  c.NotBefore.UTC().Format(time.RFC3339),
+		prefix + "cert_not_after":   c.NotAfter.UTC().Format(time.RFC3339),
+		prefix + "cert_sha256":      c.SHA256,
+		prefix + "cert_self_signed": fmt.Sprintf("%v", c.SelfSigned),
+		prefix + "cert_expired":     fmt.Sprintf("%v", c.Expired(time.Now())),
+	}
+}
+
+// TLSInfo 一次 TLS 握手的结果，FTP、SMTP、HTTP 等检测在 STARTTLS 或 implicit TLS 之后复用
+type TLSInfo struct {
+	Version     string             `json:"version"`     // 协商的 TLS 版本，例如 TLS 1.2
+	CipherSuite string             `json:"cipher"`      // 协商的加密套件
+	ALPN        string             `json:"alpn"`        // ALPN 协商的应用层协议，没有协商时为空
+	ServerName  string             `json:"server_name"` // 发送的 SNI，目标是 IP 时不发送 SNI，为空
+	Chain       []*CertificateInfo `json:"chain"`       // 服务端发送的证书链，第一个是叶子证书
+}
+
+// NewTLSInfo 根据 TLS 连接状态生成握手结果
+func NewTLSInfo(state tls.ConnectionState, serverName string) *TLSInfo {
+	if net.ParseIP(serverName) != nil {
+		// crypto/tls 不会把 IP 作为 SNI 发送
+		serverName = ""
+	}
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  serverName,
+		Chain:       make([]*CertificateInfo, 0, len(state.PeerCertificates)),
+	}
+	for _, cert := range state.PeerCertificates {
+		info.Chain = append(info.Chain, NewCertificateInfo(cert))
+	}
+	return info
+}
+
+// TLSClient 在已有连接上完成 TLS 握手，用于 implicit TLS 或 STARTTLS 升级之后
+// 握手受 conn 上已设置的截止时间限制，失败时不关闭 conn
+func TLSClient(conn net.Conn, config *tls.Config) (*tls.Conn, *TLSInfo, error) {
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, nil, err
+	}
+	return tlsConn, NewTLSInfo(tlsConn.ConnectionState(), config.ServerName), nil
+}
+
+// Leaf 返回叶子证书，服务端没有发送证书时返回 nil
+func (i TLSInfo) Leaf() *CertificateInfo {
+	if len(i.Chain) == 0 {
+		return nil
+	}
+	return i.Chain[0]
+}
+
+// Metadata 转换为扫描结果的附加信息，prefix 用于区分协议，例如 "tls_"
+// 叶子证书使用 CertificateInfo.Metadata；中间证书和根证书按在链中的位置记录，
+// 例如 tls_chain_1_cert_issuer、tls_chain_1_cert_not_after、tls_chain_1_cert_key_size
+func (i TLSInfo) Metadata(prefix string) map[string]string {
+	chain := make([]string, 0, len(i.Chain))
+	for _, cert := range i.Chain {
+		chain = append(chain, cert.Subject)
+	}
+	metadata := map[string]string{
+		prefix + "version":      i.Version,
+		prefix + "cipher":       i.CipherSuite,
+		prefix + "alpn":         i.ALPN,
+		prefix + "sni":          i.ServerName,
+		prefix + "chain":        strings.Join(chain, " | "),
+		prefix + "chain_length": fmt.Sprintf("%d", len(i.Chain)),
+	}
+	if leaf := i.Leaf(); leaf != nil {
+		for key, value := range leaf.Metadata(prefix) {
+			metadata[key] = value
+		}
+	}
+	for index, cert := range i.Chain {
+		if index == 0 {
+			continue
+		}
+		for key, value := range cert.Metadata(fmt.Sprintf("%schain_%d_", prefix, index)) {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
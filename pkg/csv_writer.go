@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,6 +21,7 @@ type CSVResult struct {
 	Status       string    `json:"status"`
 	ResponseTime string    `json:"response_time"`
 	ErrorMessage string    `json:"error_message"`
+	Details      string    `json:"details"` // 检测到的附加信息，格式为 key=value;key=value
 }
 
 // CSVWriter handles thread-safe CSV file writing with buffering
@@ -58,7 +61,7 @@ func NewCSVWriter(filePath string) (*CSVWriter, error) {
 		file:    file,
 		writer:  csv.NewWriter(file),
 		path:    filePath,
-		headers: []string{"timestamp", "scan_id", "protocol", "host", "port", "status", "response_time", "error_message"},
+		headers: []string{"timestamp", "scan_id", "protocol", "host", "port", "status", "response_time", "error_message", "details"},
 		closed:  false,
 	}
 
@@ -108,6 +111,7 @@ func (w *CSVWriter) WriteResult(result CSVResult) error {
 		result.Status,
 		result.ResponseTime,
 		result.ErrorMessage,
+		result.Details,
 	}
 
 	if err := w.writer.Write(record); err != nil {
@@ -161,4 +165,22 @@ func (w *CSVWriter) GetPath() string {
 // IsClosed returns whether the writer is closed
 func (w *CSVWriter) IsClosed() bool {
 	return w.closed
-}
\ No newline at end of file
+}
+
+// FormatMetadata 将检测附加信息格式化为按 key 排序的 key=value;key=value 字符串
+func FormatMetadata(metadata map[string]string) string {
+	if len(metadata) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+metadata[k])
+	}
+	return strings.Join(pairs, ";")
+}
@@ -2,82 +2,458 @@ package pkg
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
 	"github.com/allanpk716/go-protocol-detector/internal/common"
 	"github.com/allanpk716/go-protocol-detector/internal/custom_error"
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
 	"github.com/allanpk716/go-protocol-detector/internal/feature/ftp"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/http"
 	"github.com/allanpk716/go-protocol-detector/internal/feature/rdp"
 	"github.com/allanpk716/go-protocol-detector/internal/feature/sftp"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/smtp"
 	"github.com/allanpk716/go-protocol-detector/internal/feature/ssh"
 	"github.com/allanpk716/go-protocol-detector/internal/feature/telnet"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/tls"
 	"github.com/allanpk716/go-protocol-detector/internal/feature/vnc"
-	"net"
-	"time"
+	"github.com/allanpk716/go-protocol-detector/internal/utils"
 )
 
 type Detector struct {
-	rdp     *rdp.RDPHelper
-	ssh     *ssh.SSHHelper
-	ftp     *ftp.FTPHelper
-	timeOut time.Duration
+	rdp        *rdp.RDPHelper
+	ssh        *ssh.SSHHelper
+	ftp        *ftp.FTPHelper
+	http       *http.HTTPHelper
+	tls        *tls.TLSHelper
+	smtp       *smtp.SMTPHelper
+	timeouts   common.Timeouts
+	sourceDial dialer.DialContextFunc          // 绑定源地址/网卡的基础拨号函数，代理连接也由它发起
+	dial       dialer.DialContextFunc          // 所有检测共用的拨号函数
+	customDial bool                            // 是否通过 WithDialContext 注入了拨号函数
+	proxyURL   string                          // 代理地址，为空表示直连
+	hostKeys   *ssh.HostKeyVerifier            // SFTP 连接的主机密钥校验，nil 表示不校验
+	rateLimit  func(ctx context.Context) error // 多次握手的检测每次连接前调用，nil 表示不限制
+	logger     *log.Logger
 }
 
-func NewDetector(timeOut time.Duration) *Detector {
+// NewDetector 创建 Detector，未指定的配置使用默认值：
+// 连接超时 3s，读取超时 5s，握手超时 5s，直连，不输出日志
+func NewDetector(opts ...DetectorOption) *Detector {
 	d := Detector{
-		rdp:     rdp.NewRDPHelper(),
-		ssh:     ssh.NewSSHHelper(),
-		ftp:     ftp.NewFTPHelper(),
-		timeOut: timeOut,
+		rdp:  rdp.NewRDPHelper(),
+		ssh:  ssh.NewSSHHelper(),
+		ftp:  ftp.NewFTPHelper(),
+		http: http.NewHTTPHelper(),
+		tls:  tls.NewTLSHelper(),
+		smtp: smtp.NewSMTPHelper(),
+		timeouts: common.Timeouts{
+			Dial:      defaultDialTimeout,
+			Read:      defaultReadTimeout,
+			Handshake: defaultHandshakeTimeout,
+		},
+		sourceDial: dialer.Direct,
+		dial:       dialer.Direct,
+		logger:     log.New(io.Discard, "", 0),
+	}
+	for _, opt := range opts {
+		opt(&d)
 	}
 	return &d
 }
 
+// SetProxy 设置代理，之后所有协议检测（包括 SFTP 检测中的 SSH 客户端）都经过该代理建立连接
+// 支持 socks5://、socks5h://、http://、https://，传入空字符串恢复直连
+func (d *Detector) SetProxy(proxyURL string) error {
+	if proxyURL == "" {
+		d.dial = d.sourceDial
+		d.proxyURL = ""
+		return nil
+	}
+	dial, err := dialer.NewProxyDialer(proxyURL, d.sourceDial)
+	if err != nil {
+		return err
+	}
+	d.dial = dial
+	d.proxyURL = proxyURL
+	return nil
+}
+
+// SetSourceAddress 指定探测连接的源 IP 和/或网卡，两者都为空表示由系统选择
+// 使用代理时，到代理服务器的连接同样从该地址发起；会替换 WithDialContext 注入的拨号函数
+func (d *Detector) SetSourceAddress(sourceIP, iface string) error {
+	sourceDial, err := dialer.NewSourceDialer(sourceIP, iface)
+	if err != nil {
+		return err
+	}
+	d.sourceDial = sourceDial
+	d.customDial = false
+	return d.SetProxy(d.proxyURL)
+}
+
+// KnownHostsMode 主机密钥校验模式：strict、accept-new、insecure
+type KnownHostsMode = ssh.KnownHostsMode
+
+const (
+	KnownHostsInsecure  = ssh.KnownHostsInsecure
+	KnownHostsStrict    = ssh.KnownHostsStrict
+	KnownHostsAcceptNew = ssh.KnownHostsAcceptNew
+)
+
+// HostKeyError 主机密钥校验失败，可以用 errors.As 判断
+type HostKeyError = ssh.HostKeyError
+
+// SetKnownHosts 让 SFTP 的认证登录在发送认证信息前按照 known_hosts 校验主机密钥，无认证检测只记录主机密钥，path 为空时使用 ~/.ssh/known_hosts
+// 校验失败时返回 *HostKeyError；mode 为 KnownHostsInsecure 时不校验（默认）
+func (d *Detector) SetKnownHosts(path string, mode KnownHostsMode) error {
+	verifier, err := ssh.NewHostKeyVerifier(path, mode)
+	if err != nil {
+		return err
+	}
+	d.hostKeys = verifier
+	return nil
+}
+
+// newSFTPHelper 按照 Detector 的配置创建 SFTPHelper
+func (d Detector) newSFTPHelper(host, port string) *sftp.SFTPHelper {
+	helper := sftp.NewSFTPHelper(host, port, d.timeouts, d.dial)
+	helper.SetHostKeyVerifier(d.hostKeys)
+	return helper
+}
+
 func (d Detector) RDPCheck(host, port string) error {
 	return d.commonCheck(host, port, d.rdp.SenderPackage, d.rdp.ReceiverFeatures, custom_error.ErrRDPNotFound)
 }
 
+// RDPSecurityInfo RDP 服务端支持的安全协议
+type RDPSecurityInfo = rdp.SecurityInfo
+
+// RDPSecurityCheck 检测 RDP 服务并逐个协商 Standard RDP、TLS、CredSSP、CredSSP-EX、RDSTLS，
+// 报告服务端支持的安全协议以及是否强制 NLA
+func (d Detector) RDPSecurityCheck(host, port string) (*RDPSecurityInfo, error) {
+	info, err := d.rdp.CheckSecurity(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrRDPNotFound, err)
+		return nil, custom_error.ErrRDPNotFound
+	}
+	return info, nil
+}
+
+// CertificateInfo 服务端 TLS 证书信息
+type CertificateInfo = utils.CertificateInfo
+
+// RDPCertificateCheck 协商 TLS 后获取 RDP 服务端证书，服务端只支持 Standard RDP 时返回 ErrRDPNotFound
+func (d Detector) RDPCertificateCheck(host, port string) (*CertificateInfo, error) {
+	info, err := d.rdp.Certificate(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s rdp certificate failed: %v", host, port, err)
+		return nil, custom_error.ErrRDPNotFound
+	}
+	return info, nil
+}
+
+// RDPOSInfo 通过 NTLM CHALLENGE 获取的 RDP 服务端系统信息
+type RDPOSInfo = rdp.OSInfo
+
+// RDPFingerprint 在 TLS 之上发起 CredSSP 交互，不提供凭据，只读取服务端返回的 NTLM CHALLENGE，
+// 获取 Windows 版本号、计算机名和域名。服务端不支持 CredSSP 时返回 ErrRDPNotFound
+func (d Detector) RDPFingerprint(host, port string) (*RDPOSInfo, error) {
+	info, err := d.rdp.Fingerprint(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s rdp fingerprint failed: %v", host, port, err)
+		return nil, custom_error.ErrRDPNotFound
+	}
+	return info, nil
+}
+
 func (d Detector) SSHCheck(host, port string) error {
 	return d.commonCheck(host, port, d.ssh.SenderPackage, d.ssh.ReceiverFeatures, custom_error.ErrSSHNotFound)
 }
 
+// SSHBannerInfo 解析后的 SSH 版本标识
+type SSHBannerInfo = ssh.BannerInfo
+
+// SSHBannerCheck 与 SSHCheck 相同的检测，同时把服务端版本标识解析为协议版本、软件名称、软件版本和注释
+func (d Detector) SSHBannerCheck(host, port string) (*SSHBannerInfo, error) {
+	info, err := d.ssh.Banner(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrSSHNotFound, err)
+		return nil, custom_error.ErrSSHNotFound
+	}
+	return info, nil
+}
+
+// SSHAlgorithmInfo SSH 服务端声明的算法以及其中不安全的算法
+type SSHAlgorithmInfo = ssh.AlgorithmInfo
+
+// SSHAlgorithmCheck 发送客户端 KEXINIT 并解析服务端 KEXINIT，报告 kex、主机密钥、加密、MAC、压缩算法，
+// 并标记 diffie-hellman-group1-sha1、CBC 加密、hmac-md5 等不安全算法
+func (d Detector) SSHAlgorithmCheck(host, port string) (*SSHAlgorithmInfo, error) {
+	info, err := d.ssh.CheckAlgorithms(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrSSHNotFound, err)
+		return nil, custom_error.ErrSSHNotFound
+	}
+	return info, nil
+}
+
+// SSHHostKeyInfo SSH 服务端主机密钥类型和 SHA256 指纹
+type SSHHostKeyInfo = ssh.HostKeyInfo
+
+// SSHHostKeyCheck 完成密钥交换获取服务端主机密钥，不进行认证
+func (d Detector) SSHHostKeyCheck(host, port string) (*SSHHostKeyInfo, error) {
+	info, err := d.ssh.HostKey(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrSSHNotFound, err)
+		return nil, custom_error.ErrSSHNotFound
+	}
+	return info, nil
+}
+
+// SSHAuthInfo SSH 服务端允许的认证方法
+type SSHAuthInfo = ssh.AuthInfo
+
+// SSHAuthMethodsCheck 以 "none" 方式认证，报告服务端声明的认证方法（publickey、password、keyboard-interactive、
+// gssapi-with-mic），用于找出允许密码登录的服务器，不提交任何凭据
+func (d Detector) SSHAuthMethodsCheck(host, port string) (*SSHAuthInfo, error) {
+	info, err := d.ssh.AuthMethods(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrSSHNotFound, err)
+		return nil, custom_error.ErrSSHNotFound
+	}
+	return info, nil
+}
+
 func (d Detector) FTPCheck(host, port string) error {
 	return d.commonCheck(host, port, d.ftp.SenderPackage, d.ftp.ReceiverFeatures, custom_error.ErrFTPNotFound)
 }
 
+// FTPInfo FTP 服务端的欢迎信息、系统类型、FEAT 功能列表以及是否允许匿名登录
+type FTPInfo = ftp.FTPInfo
+
+// FTPCapabilitiesCheck 读取完整的 220 欢迎信息并发送 FEAT、SYST，tryAnonymous 为 true 时尝试匿名登录
+func (d Detector) FTPCapabilitiesCheck(host, port string, tryAnonymous bool) (*FTPInfo, error) {
+	info, err := d.ftp.Capabilities(net.JoinHostPort(host, port), d.timeouts, d.dial, tryAnonymous)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrFTPNotFound, err)
+		return nil, custom_error.ErrFTPNotFound
+	}
+	return info, nil
+}
+
+// FTPSInfo FTPS 协商的 TLS 版本、加密套件和服务端证书
+type FTPSInfo = ftp.FTPSInfo
+
+// FTPSExplicitCheck 在 FTP 明文连接上发送 AUTH TLS 并完成 TLS 握手，服务端拒绝或握手失败时返回 ErrFTPSNotFound
+func (d Detector) FTPSExplicitCheck(host, port string) (*FTPSInfo, error) {
+	info, err := d.ftp.ExplicitTLS(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrFTPSNotFound, err)
+		return nil, custom_error.ErrFTPSNotFound
+	}
+	return info, nil
+}
+
+// FTPSImplicitCheck 检测 implicit FTPS（通常是 990 端口）：直接进行 TLS 握手后读取 220 欢迎信息
+func (d Detector) FTPSImplicitCheck(host, port string) (*FTPSInfo, error) {
+	info, err := d.ftp.ImplicitTLS(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrFTPSNotFound, err)
+		return nil, custom_error.ErrFTPSNotFound
+	}
+	return info, nil
+}
+
+// SFTPCheck 无需认证凭据，直接进行SFTP子系统探测，user、password、privateKeyFullPath 不会使用
+// 返回 nil 表示确认支持 SFTP；ErrSFTPAuthRequired 表示是 SSH 服务但服务端要求认证，无法确认；其余情况返回 ErrSFTPNotFound
 func (d Detector) SFTPCheck(host, port, user, password, privateKeyFullPath string) error {
-	// 新的SFTP检测逻辑：无需认证凭据，直接进行SFTP子系统探测
-	return sftp.NewSFTPHelper(host, port, d.timeOut).Check("", "", "")
+	return d.newSFTPHelper(host, port).Check("", "", "")
+}
+
+// SFTPStatus SFTP 检测的三值结果
+type SFTPStatus = sftp.SFTPStatus
+
+const (
+	SFTPStatusConfirmed = sftp.SFTPStatusConfirmed
+	SFTPStatusUnknown   = sftp.SFTPStatusUnknown
+	SFTPStatusNotFound  = sftp.SFTPStatusNotFound
+)
+
+// SSHAuthOptions 认证登录使用的凭据：密码、多个私钥（自动使用同名的 -cert.pub 证书）、ssh-agent、keyboard-interactive
+type SSHAuthOptions = sftp.AuthOptions
+
+// NewSSHAuthOptions 按照原有的 user、password、privateKeyFullPath 参数生成认证凭据，指定私钥时 password 是私钥的密码
+func NewSSHAuthOptions(user, password, privateKeyFullPath string) SSHAuthOptions {
+	return sftp.NewAuthOptions(user, password, privateKeyFullPath)
+}
+
+// SFTPCheckWithFallback 先做无认证检测，服务端要求认证且提供了认证凭据时，登录后确认 SFTP 是否可用
+func (d Detector) SFTPCheckWithFallback(host, port string, auth SSHAuthOptions) (*SFTPDiagnostics, error) {
+	return d.newSFTPHelper(host, port).CheckWithFallback(auth)
+}
+
+// SFTPDiagnostics SFTP 检测的诊断信息，包含服务端主机密钥
+type SFTPDiagnostics = sftp.SFTPDiagnostics
+
+// SFTPCheckWithDiagnostics 与 SFTPCheck 相同的无认证检测，同时返回 banner、主机密钥等诊断信息
+func (d Detector) SFTPCheckWithDiagnostics(host, port string) (*SFTPDiagnostics, error) {
+	return d.newSFTPHelper(host, port).CheckWithDiagnostics()
+}
+
+// SFTPAuditInfo 认证登录后读取的 SFTP 服务端能力
+type SFTPAuditInfo = sftp.SFTPAuditInfo
+
+// SFTPAudit 使用认证凭据登录，报告 SFTP 协议版本、服务端扩展、工作目录以及是否被 chroot
+// writeTest 为 true 时会在工作目录创建并删除一个测试文件来判断是否可写
+func (d Detector) SFTPAudit(host, port string, auth SSHAuthOptions, writeTest bool) (*SFTPAuditInfo, error) {
+	return d.newSFTPHelper(host, port).Audit(auth, writeTest)
 }
 
 // 保留原有的认证式SFTP检测方法（向后兼容）
 func (d Detector) SFTPCheckWithAuth(host, port, user, password, privateKeyFullPath string) error {
-	return sftp.NewSFTPHelper(host, port, d.timeOut).CheckWithAuth(user, password, privateKeyFullPath)
+	return d.newSFTPHelper(host, port).CheckWithAuth(user, password, privateKeyFullPath)
+}
+
+// SFTPCheckWithAuthOptions 使用 ssh-agent、多个私钥、证书、密码或 keyboard-interactive 登录后确认 SFTP 可用
+func (d Detector) SFTPCheckWithAuthOptions(host, port string, auth SSHAuthOptions) error {
+	return d.newSFTPHelper(host, port).CheckWithAuthOptions(auth)
 }
 
 func (d Detector) TelnetCheck(host, port string) error {
 
-	tel, err := telnet.NewTelnetHelper("tcp", net.JoinHostPort(host, port), d.timeOut)
+	tel, err := telnet.NewTelnetHelper("tcp", net.JoinHostPort(host, port), d.timeouts, d.dial)
 	if err != nil {
 		return custom_error.ErrTelnetNotFound
 	}
-	n, err := tel.Check()
-	if err != nil || n <= 0 {
+	defer tel.Close()
+	if _, err := tel.Check(); err != nil {
 		return custom_error.ErrTelnetNotFound
 	}
 	return nil
 }
 
+// SMTPInfo SMTP 服务端的欢迎信息、EHLO 扩展、认证方式以及 TLS 信息
+type SMTPInfo = smtp.SMTPInfo
+
+// SMTPCheck 识别明文或 implicit TLS（465 端口先尝试）的 SMTP 服务，发送 EHLO 解析扩展，标记明文连接上提供 AUTH PLAIN/LOGIN 的服务端
+// 554 等拒绝服务的欢迎信息同样认为是 SMTP，只记录应答码
+// startTLS 为 true 且服务端声明 STARTTLS 时升级连接，记录 TLS 版本、加密套件、证书以及升级后的认证方式
+func (d Detector) SMTPCheck(host, port string, startTLS bool) (*SMTPInfo, error) {
+	info, err := d.smtp.Check(net.JoinHostPort(host, port), d.timeouts, d.dial, startTLS)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrSMTPNotFound, err)
+		return nil, custom_error.ErrSMTPNotFound
+	}
+	return info, nil
+}
+
+// HTTPInfo HTTP/HTTPS 服务的状态码、Server、标题、跳转地址和 favicon 哈希
+type HTTPInfo = http.HTTPInfo
+
+// HTTPCheck 在同一个端口上区分 HTTPS 和 HTTP，发送 GET / 并记录响应信息，不跟随跳转
+func (d Detector) HTTPCheck(host, port string) (*HTTPInfo, error) {
+	info, err := d.http.Check(net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrHTTPNotFound, err)
+		return nil, custom_error.ErrHTTPNotFound
+	}
+	return info, nil
+}
+
+// TLSInfo TLS 握手协商的版本、加密套件、ALPN、证书链以及 SNI 行为
+type TLSInfo = tls.TLSInfo
+
+// TLSCheck 在任意端口上完成 TLS 握手并记录证书链
+// serverName 为空且 host 是域名时使用 host；有域名时会比较发送和不发送 SNI 的结果
+func (d Detector) TLSCheck(host, port, serverName string) (*TLSInfo, error) {
+	if serverName == "" {
+		serverName = host
+	}
+	info, err := d.tls.Check(net.JoinHostPort(host, port), serverName, d.timeouts, d.dial)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrTLSNotFound, err)
+		return nil, custom_error.ErrTLSNotFound
+	}
+	return info, nil
+}
+
+// TLSEnumeration TLS 版本和加密套件枚举结果以及 weak/strong 评级
+type TLSEnumeration = tls.TLSEnumeration
+
+// TLSEnumerate 对 SSL 3.0 到 TLS 1.3 逐个版本反复握手，列出服务端接受的版本和加密套件并评级
+// 每次握手前都会经过 WithRateLimit 设置的速率限制，ctx 取消时停止等待并结束枚举
+func (d Detector) TLSEnumerate(ctx context.Context, host, port, serverName string) (*TLSEnumeration, error) {
+	if serverName == "" {
+		serverName = host
+	}
+	var wait func() error
+	if d.rateLimit != nil {
+		wait = func() error { return d.rateLimit(ctx) }
+	}
+	info, err := d.tls.Enumerate(net.JoinHostPort(host, port), serverName, d.timeouts, d.dial, wait)
+	if err != nil {
+		d.logger.Printf("%s:%s %v: %v", host, port, custom_error.ErrTLSNotFound, err)
+		return nil, custom_error.ErrTLSNotFound
+	}
+	return info, nil
+}
+
+// TelnetInfo Telnet 服务端的选项协商记录、欢迎信息、登录提示和识别出的设备类型
+type TelnetInfo = telnet.TelnetInfo
+
+// TelnetBannerCheck 拒绝服务端的所有选项，记录其请求的 DO/WILL 选项并读取欢迎信息和登录提示
+func (d Detector) TelnetBannerCheck(host, port string) (*TelnetInfo, error) {
+	tel, err := telnet.NewTelnetHelper("tcp", net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		return nil, custom_error.ErrTelnetNotFound
+	}
+	defer tel.Close()
+	info, err := tel.Banner()
+	if err != nil {
+		return nil, custom_error.ErrTelnetNotFound
+	}
+	return info, nil
+}
+
 func (d Detector) VNCCheck(host, port string) error {
 
-	vnc, err := vnc.NewVNCHelper("tcp", net.JoinHostPort(host, port), d.timeOut)
+	vnc, err := vnc.NewVNCHelper("tcp", net.JoinHostPort(host, port), d.timeouts, d.dial)
 	if err != nil {
 		return custom_error.ErrVNCNotFound
 	}
+	defer vnc.Close()
 	return vnc.Check()
 }
 
+// VNCInfo VNC 服务端的 RFB 协议版本以及支持的安全类型
+type VNCInfo = vnc.VNCInfo
+
+// VNCSecurityCheck 读取 RFB 协议版本并列出服务端提供的安全类型，NoAuth 为 true 表示不需要密码即可连接
+// 协议版本合法而读取安全类型失败时仍返回已读取的信息
+func (d Detector) VNCSecurityCheck(host, port string) (*VNCInfo, error) {
+	helper, err := vnc.NewVNCHelper("tcp", net.JoinHostPort(host, port), d.timeouts, d.dial)
+	if err != nil {
+		return nil, custom_error.ErrVNCNotFound
+	}
+	defer helper.Close()
+	info, err := helper.SecurityTypes()
+	if info == nil {
+		return nil, custom_error.ErrVNCNotFound
+	}
+	if err != nil {
+		d.logger.Printf("%s:%s vnc security types: %v", host, port, err)
+	}
+	return info, nil
+}
+
 func (d Detector) CommonPortCheck(host, port string) error {
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), d.timeOut)
+	conn, err := dialer.DialTimeout(d.dial, "tcp", net.JoinHostPort(host, port), d.timeouts.Dial)
 	if err != nil {
 		return custom_error.ErrCommontPortCheckError
 	}
@@ -85,16 +461,84 @@ func (d Detector) CommonPortCheck(host, port string) error {
 	return nil
 }
 
+// HostAliveCheck 判断主机是否存活：并发对常见端口发起 TCP 连接（连接成功或被 RST 拒绝都说明主机在线），
+// useICMP 为 true 时额外发送 ICMP Echo（没有原始套接字权限时 ICMP 探测会失败，使用代理时不进行 ICMP 探测）
+func (d Detector) HostAliveCheck(host string, ports []int, useICMP bool) error {
+	// 连接超时 <= 0 表示不限制，与 dialer.DialTimeout 一致
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if d.timeouts.Dial > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, d.timeouts.Dial)
+		defer cancelTimeout()
+	}
+
+	// 经过代理或使用注入的拨号函数时无法发送 ICMP 报文，只能依赖 TCP 探测
+	if d.proxyURL != "" || d.customDial {
+		useICMP = false
+	}
+
+	probeCount := len(ports)
+	if useICMP {
+		probeCount++
+	}
+	if probeCount == 0 {
+		return custom_error.ErrHostNotAlive
+	}
+	// 带缓冲，提前返回时剩余的探测 goroutine 也不会阻塞
+	aliveChan := make(chan bool, probeCount)
+
+	for _, port := range ports {
+		go func(port int) {
+			conn, err := d.dial(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+			if err != nil {
+				aliveChan <- isConnRefused(err)
+				return
+			}
+			conn.Close()
+			aliveChan <- true
+		}(port)
+	}
+	if useICMP {
+		go func() {
+			aliveChan <- utils.ICMPEcho(ctx, host, d.sourceDial) == nil
+		}()
+	}
+
+	for i := 0; i < probeCount; i++ {
+		select {
+		case alive := <-aliveChan:
+			if alive {
+				return nil
+			}
+		case <-ctx.Done():
+			return custom_error.ErrHostNotAlive
+		}
+	}
+	return custom_error.ErrHostNotAlive
+}
+
+// isConnRefused 判断是否为对端主动拒绝连接
+func isConnRefused(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	// Windows 下的错误码与 syscall.ECONNREFUSED 不一致，退化为匹配错误信息
+	return strings.Contains(err.Error(), "refused")
+}
+
 func (d Detector) commonCheck(host string, port string,
 	senderPackage []byte, recFeatures []common.ReceiverFeature, outErr error) error {
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), d.timeOut)
+	conn, err := dialer.DialTimeout(d.dial, "tcp", net.JoinHostPort(host, port), d.timeouts.Dial)
 	if err != nil {
+		d.logger.Printf("%s:%s %v: dial failed: %v", host, port, outErr, err)
 		return outErr
 	}
 	defer conn.Close()
 
 	_, err = conn.Write(senderPackage)
 	if err != nil {
+		d.logger.Printf("%s:%s %v: write failed: %v", host, port, outErr, err)
 		return outErr
 	}
 	lastFeature := recFeatures[len(recFeatures)-1]
@@ -112,7 +556,7 @@ func (d Detector) commonCheck(host string, port string,
 	var readBuf = make([]byte, readBytesLen)
 
 	// 设置读取超时，防止阻塞
-	err = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	err = conn.SetReadDeadline(common.Deadline(d.timeouts.Read))
 	if err != nil {
 		return outErr
 	}
@@ -120,11 +564,13 @@ func (d Detector) commonCheck(host string, port string,
 	// 使用io.ReadFull确保读取指定大小的数据或返回错误
 	_, err = io.ReadFull(conn, readBuf)
 	if err != nil {
+		d.logger.Printf("%s:%s %v: read failed: %v", host, port, outErr, err)
 		return outErr
 	}
 	// according to the features
 	for _, feature := range recFeatures {
 		if bytes.Equal(readBuf[feature.StartIndex:feature.StartIndex+len(feature.FeatureBytes)], feature.FeatureBytes) == false {
+			d.logger.Printf("%s:%s %v: response %q does not match", host, port, outErr, readBuf)
 			return outErr
 		}
 	}
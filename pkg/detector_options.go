@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/allanpk716/go-protocol-detector/internal/dialer"
+)
+
+const (
+	defaultDialTimeout      = 3 * time.Second
+	defaultReadTimeout      = 5 * time.Second
+	defaultHandshakeTimeout = 5 * time.Second
+)
+
+// DialContextFunc 建立网络连接的函数，签名与 net.Dialer.DialContext 一致
+// 可以用来注入自定义传输层，例如单元测试中返回 net.Pipe 的一端
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DetectorOption Detector 的函数式配置项
+type DetectorOption func(*Detector)
+
+// WithTimeout 同时设置建立连接、读取响应和协议握手三个阶段的超时，<= 0 表示不限制
+func WithTimeout(timeout time.Duration) DetectorOption {
+	return func(d *Detector) {
+		d.timeouts.Dial = timeout
+		d.timeouts.Read = timeout
+		d.timeouts.Handshake = timeout
+	}
+}
+
+// WithDialTimeout 设置建立 TCP 连接的超时，<= 0 表示不限制
+func WithDialTimeout(timeout time.Duration) DetectorOption {
+	return func(d *Detector) {
+		d.timeouts.Dial = timeout
+	}
+}
+
+// WithReadTimeout 设置等待服务端响应的超时，<= 0 表示不限制
+func WithReadTimeout(timeout time.Duration) DetectorOption {
+	return func(d *Detector) {
+		d.timeouts.Read = timeout
+	}
+}
+
+// WithHandshakeTimeout 设置协议握手（例如 SSH 握手）的超时，<= 0 表示不限制
+func WithHandshakeTimeout(timeout time.Duration) DetectorOption {
+	return func(d *Detector) {
+		d.timeouts.Handshake = timeout
+	}
+}
+
+// WithDialContext 替换所有检测使用的拨号函数
+// 之后调用 SetProxy 时，到代理服务器的连接同样通过该函数建立
+func WithDialContext(dial DialContextFunc) DetectorOption {
+	return func(d *Detector) {
+		if dial == nil {
+			return
+		}
+		d.sourceDial = dialer.DialContextFunc(dial)
+		d.dial = dialer.DialContextFunc(dial)
+		d.customDial = true
+	}
+}
+
+// WithLogger 设置 Detector 输出检测细节所用的日志，默认不输出
+func WithLogger(logger *log.Logger) DetectorOption {
+	return func(d *Detector) {
+		if logger == nil {
+			logger = log.New(io.Discard, "", 0)
+		}
+		d.logger = logger
+	}
+}
+
+// WithRateLimit 设置需要多次握手的检测（例如 TLS 版本和加密套件枚举）在每次连接前的等待函数，
+// 用于接入扫描的速率限制，默认不限制
+func WithRateLimit(wait func(ctx context.Context) error) DetectorOption {
+	return func(d *Detector) {
+		d.rateLimit = wait
+	}
+}
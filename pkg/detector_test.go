@@ -1,9 +1,34 @@
 package pkg
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
 	"github.com/allanpk716/go-protocol-detector/internal/custom_error"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"io"
+	"math/big"
+	"net"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -52,11 +77,11 @@ func getOptionalTestEnvInt(key string, defaultValue int) int {
 }
 
 func TestNewDetector(t *testing.T) {
-	NewDetector(timeOut)
+	NewDetector(WithTimeout(timeOut))
 }
 
 func TestDetector_RDPCheck(t *testing.T) {
-	det := NewDetector(timeOut)
+	det := NewDetector(WithTimeout(timeOut))
 	if det.rdp.GetVersion() == "" {
 		t.Fatal("rdp version is empty")
 	}
@@ -82,7 +107,7 @@ func TestDetector_RDPCheck(t *testing.T) {
 }
 
 func TestDetector_SSHCheck(t *testing.T) {
-	det := NewDetector(timeOut)
+	det := NewDetector(WithTimeout(timeOut))
 	if det.ssh.GetVersion() == "" {
 		t.Fatal("ssh version is empty")
 	}
@@ -105,7 +130,7 @@ func TestDetector_SSHCheck(t *testing.T) {
 }
 
 func TestDetector_FTPCheck(t *testing.T) {
-	det := NewDetector(timeOut)
+	det := NewDetector(WithTimeout(timeOut))
 	if det.ftp.GetVersion() == "" {
 		t.Fatal("ftp version is empty")
 	}
@@ -128,7 +153,7 @@ func TestDetector_FTPCheck(t *testing.T) {
 }
 
 func TestDetector_SFTPCheck(t *testing.T) {
-	det := NewDetector(timeOut)
+	det := NewDetector(WithTimeout(timeOut))
 
 	// 测试SFTP协议检测（无需认证信息）
 	testHost := getTestEnv("TEST_SFTP_HOST")
@@ -176,7 +201,7 @@ func TestDetector_SFTPCheck(t *testing.T) {
 }
 
 func TestDetector_TelnetCheck(t *testing.T) {
-	det := NewDetector(timeOut)
+	det := NewDetector(WithTimeout(timeOut))
 
 	// 测试有效的Telnet服务（需要设置环境变量 TEST_TELNET_HOST 和 TEST_TELNET_PORT）
 	testHost := getTestEnv("TEST_TELNET_HOST")
@@ -196,7 +221,7 @@ func TestDetector_TelnetCheck(t *testing.T) {
 }
 
 func TestDetector_VNCCheck(t *testing.T) {
-	det := NewDetector(timeOut)
+	det := NewDetector(WithTimeout(timeOut))
 
 	// 测试有效的VNC服务（需要设置环境变量 TEST_VNC_HOST 和 TEST_VNC_PORT）
 	testHost := getTestEnv("TEST_VNC_HOST")
@@ -216,7 +241,7 @@ func TestDetector_VNCCheck(t *testing.T) {
 }
 
 func TestDetector_CommonPortCheck(t *testing.T) {
-	det := NewDetector(timeOut)
+	det := NewDetector(WithTimeout(timeOut))
 
 	// 测试有效的通用端口（需要设置环境变量 TEST_COMMON_HOST 和 TEST_COMMON_PORT）
 	testHost := getTestEnv("TEST_COMMON_HOST")
@@ -234,3 +259,1162 @@ func TestDetector_CommonPortCheck(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDetector_SetSourceAddress(t *testing.T) {
+	sshAddr := startBannerServer(t, "SSH-2.0-OpenSSH_8.9p1\r\n")
+	sshHost, sshPort, _ := net.SplitHostPort(sshAddr)
+
+	det := NewDetector(WithTimeout(timeOut))
+	if err := det.SetSourceAddress("127.0.0.1", ""); err != nil {
+		t.Fatalf("SetSourceAddress failed: %v", err)
+	}
+	if err := det.SSHCheck(sshHost, sshPort); err != nil {
+		t.Errorf("SSH check with source address failed: %v", err)
+	}
+
+	// 无效的源地址和不存在的网卡
+	if err := det.SetSourceAddress("not-an-ip", ""); err == nil {
+		t.Error("Expected error for invalid source ip")
+	}
+	if err := det.SetSourceAddress("", "no-such-iface0"); err == nil {
+		t.Error("Expected error for unknown interface")
+	}
+}
+
+// pipeDialer 返回一个拨号函数，每次拨号都创建 net.Pipe 并在另一端运行 handler 模拟服务端
+func pipeDialer(handler func(conn net.Conn)) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			handler(server)
+		}()
+		return client, nil
+	}
+}
+
+// TestDetector_ZeroTimeouts 测试超时为 0 表示不设置截止时间，而不是立即超时
+func TestDetector_ZeroTimeouts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	if err := NewDetector(WithTimeout(0)).HostAliveCheck("127.0.0.1", []int{listener.Addr().(*net.TCPAddr).Port}, false); err != nil {
+		t.Errorf("Expected host alive without dial timeout, got %v", err)
+	}
+
+	det := NewDetector(WithTimeout(time.Second), WithReadTimeout(0), WithHandshakeTimeout(0),
+		WithDialContext(pipeDialer(smtpTestServer(nil, nil, []string{"PIPELINING"}))))
+	if info, err := det.SMTPCheck("mail.test", "25", false); err != nil || !info.Pipelining {
+		t.Errorf("Expected SMTP found without read timeout, got %+v (%v)", info, err)
+	}
+	det = NewDetector(WithTimeout(time.Second), WithHandshakeTimeout(0), WithDialContext(pipeDialer(sniTestServer(t, false))))
+	if _, err := det.TLSCheck("www.test", "443", ""); err != nil {
+		t.Errorf("Expected TLS found without handshake timeout, got %v", err)
+	}
+}
+
+func TestDetector_WithDialContext(t *testing.T) {
+	sshServer := func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	}
+	vncServer := func(conn net.Conn) {
+		conn.Write([]byte("RFB 003.008\n"))
+	}
+	httpServer := func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(sshServer)))
+	if err := det.SSHCheck("ssh.test", "22"); err != nil {
+		t.Errorf("Expected SSH found over pipe, got %v", err)
+	}
+
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(vncServer)))
+	if err := det.VNCCheck("vnc.test", "5900"); err != nil {
+		t.Errorf("Expected VNC found over pipe, got %v", err)
+	}
+
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(httpServer)))
+	if err := det.SSHCheck("http.test", "80"); err != custom_error.ErrSSHNotFound {
+		t.Errorf("Expected ErrSSHNotFound for HTTP server, got %v", err)
+	}
+	if err := det.VNCCheck("http.test", "80"); err != custom_error.ErrVNCNotFound {
+		t.Errorf("Expected ErrVNCNotFound for HTTP server, got %v", err)
+	}
+}
+
+// rdpNegotiationServer 模拟只接受 CredSSP 的 RDP 服务端（强制 NLA）
+// 与 Windows 一致，只有同时请求 HYBRID 时才会选择 HYBRID_EX
+func rdpNegotiationServer(conn net.Conn) {
+	request := make([]byte, 19)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+	requested := binary.LittleEndian.Uint32(request[15:])
+
+	response := []byte{0x03, 0x00, 0x00, 0x13, 0x0e, 0xd0, 0x00, 0x00, 0x12, 0x34, 0x00,
+		0x02, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}
+	switch {
+	case requested&0x0a == 0x0a:
+		binary.LittleEndian.PutUint32(response[15:], 0x08)
+	case requested&0x02 != 0:
+		binary.LittleEndian.PutUint32(response[15:], 0x02)
+	default:
+		response[11] = 0x03 // RDP_NEG_FAILURE
+		binary.LittleEndian.PutUint32(response[15:], 0x05)
+	}
+	conn.Write(response)
+}
+
+func TestDetector_RDPSecurityCheck(t *testing.T) {
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(rdpNegotiationServer)))
+	info, err := det.RDPSecurityCheck("rdp.test", "3389")
+	if err != nil {
+		t.Fatalf("RDP security check failed: %v", err)
+	}
+	if info.StandardRDP || info.TLS || info.RDSTLS {
+		t.Errorf("Unexpected supported protocols: %v", info.SupportedProtocols())
+	}
+	if !info.CredSSP || !info.CredSSPEx {
+		t.Errorf("Expected CredSSP and CredSSP-EX supported, got %v", info.SupportedProtocols())
+	}
+	if !info.NLAEnforced {
+		t.Error("Expected NLA enforced")
+	}
+	if info.FailureCodes["RDP"] != "HYBRID_REQUIRED_BY_SERVER" {
+		t.Errorf("Unexpected failure code for RDP: %s", info.FailureCodes["RDP"])
+	}
+
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	})))
+	if _, err := det.RDPSecurityCheck("ssh.test", "22"); err != custom_error.ErrRDPNotFound {
+		t.Errorf("Expected ErrRDPNotFound for SSH server, got %v", err)
+	}
+}
+
+// generateTestCertificate 生成测试用的自签名证书
+func generateTestCertificate(t *testing.T, commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// ntlmChallengeMessage 构造携带版本号和 TargetInfo 的 NTLM CHALLENGE
+func ntlmChallengeMessage(major, minor byte, build uint16, avPairs map[uint16]string) []byte {
+	utf16le := func(s string) []byte {
+		b := make([]byte, 0, len(s)*2)
+		for _, r := range s {
+			b = append(b, byte(r), 0)
+		}
+		return b
+	}
+	targetInfo := make([]byte, 0)
+	for _, id := range []uint16{1, 2, 3, 4} {
+		value := utf16le(avPairs[id])
+		targetInfo = binary.LittleEndian.AppendUint16(targetInfo, id)
+		targetInfo = binary.LittleEndian.AppendUint16(targetInfo, uint16(len(value)))
+		targetInfo = append(targetInfo, value...)
+	}
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+
+	msg := make([]byte, 56)
+	copy(msg, "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[20:], 0x02800000) // VERSION | TARGET_INFO
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(msg[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(msg[44:], 56)
+	msg[48], msg[49] = major, minor
+	binary.LittleEndian.PutUint16(msg[50:], build)
+	return append(msg, targetInfo...)
+}
+
+// rdpCredSSPServer 模拟支持 CredSSP 的 RDP 服务端，收到 TSRequest 后返回 NTLM CHALLENGE
+func rdpCredSSPServer(cert tls.Certificate) func(net.Conn) {
+	return func(conn net.Conn) {
+		rdpNegotiationServer(conn)
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		if _, err := tlsConn.Read(make([]byte, 1024)); err != nil {
+			return
+		}
+
+		type negoToken struct {
+			Data []byte `asn1:"explicit,tag:0"`
+		}
+		type tsRequest struct {
+			Version    int         `asn1:"explicit,tag:0"`
+			NegoTokens []negoToken `asn1:"explicit,tag:1"`
+		}
+		challenge := ntlmChallengeMessage(10, 0, 17763, map[uint16]string{
+			1: "WIN-TEST", 2: "CORP", 3: "win-test.corp.local", 4: "corp.local",
+		})
+		response, _ := asn1.Marshal(tsRequest{Version: 6, NegoTokens: []negoToken{{Data: challenge}}})
+		tlsConn.Write(response)
+	}
+}
+
+func TestDetector_RDPFingerprint(t *testing.T) {
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(rdpCredSSPServer(generateTestCertificate(t, "WIN-TEST")))))
+	info, err := det.RDPFingerprint("rdp.test", "3389")
+	if err != nil {
+		t.Fatalf("RDP fingerprint failed: %v", err)
+	}
+	if info.OSVersion != "10.0.17763" || info.Product != "2019" {
+		t.Errorf("Unexpected os version %q product %q", info.OSVersion, info.Product)
+	}
+	if info.NetBIOSComputer != "WIN-TEST" || info.NetBIOSDomain != "CORP" {
+		t.Errorf("Unexpected NetBIOS names %q %q", info.NetBIOSComputer, info.NetBIOSDomain)
+	}
+	if info.DNSComputer != "win-test.corp.local" || info.DNSDomain != "corp.local" {
+		t.Errorf("Unexpected DNS names %q %q", info.DNSComputer, info.DNSDomain)
+	}
+	if info.Metadata()["rdp_os_product"] != "2019" {
+		t.Errorf("Unexpected metadata: %v", info.Metadata())
+	}
+
+	// 只完成协商、不支持 TLS 的服务端
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(rdpNegotiationServer)))
+	if _, err := det.RDPFingerprint("rdp.test", "3389"); err != custom_error.ErrRDPNotFound {
+		t.Errorf("Expected ErrRDPNotFound without TLS, got %v", err)
+	}
+}
+
+func TestDetector_RDPCertificateCheck(t *testing.T) {
+	cert := generateTestCertificate(t, "WIN-TEST")
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(rdpCredSSPServer(cert))))
+	info, err := det.RDPCertificateCheck("rdp.test", "3389")
+	if err != nil {
+		t.Fatalf("RDP certificate check failed: %v", err)
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	if info.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected fingerprint %s", info.SHA256)
+	}
+	if info.SubjectCN != "WIN-TEST" || !info.SelfSigned {
+		t.Errorf("Unexpected certificate info: %+v", info)
+	}
+	metadata := info.Metadata("rdp_")
+	if metadata["rdp_cert_expired"] != "false" || metadata["rdp_cert_self_signed"] != "true" {
+		t.Errorf("Unexpected metadata: %v", metadata)
+	}
+
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(rdpNegotiationServer)))
+	if _, err := det.RDPCertificateCheck("rdp.test", "3389"); err != custom_error.ErrRDPNotFound {
+		t.Errorf("Expected ErrRDPNotFound without TLS, got %v", err)
+	}
+}
+
+// sshKexInitServer 模拟发送 KEXINIT 的 SSH 服务端，lists 为 10 个逗号分隔的 name-list
+func sshKexInitServer(lists [10]string) func(net.Conn) {
+	return func(conn net.Conn) {
+		conn.Write([]byte("SSH-2.0-OpenSSH_7.4\r\n"))
+		reader := bufio.NewReader(conn)
+		if _, err := reader.ReadString('\n'); err != nil {
+			return
+		}
+		go io.Copy(io.Discard, reader)
+
+		payload := append([]byte{20}, make([]byte, 16)...)
+		for _, list := range lists {
+			payload = binary.BigEndian.AppendUint32(payload, uint32(len(list)))
+			payload = append(payload, list...)
+		}
+		payload = append(payload, 0, 0, 0, 0, 0)
+		padding := 8 - (5+len(payload))%8
+		if padding < 4 {
+			padding += 8
+		}
+		packet := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)+padding))
+		packet = append(packet, byte(padding))
+		packet = append(packet, payload...)
+		conn.Write(append(packet, make([]byte, padding)...))
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestDetector_SSHAlgorithmCheck(t *testing.T) {
+	server := sshKexInitServer([10]string{
+		"curve25519-sha256,diffie-hellman-group1-sha1",
+		"rsa-sha2-512,ssh-dss",
+		"aes128-ctr,aes128-cbc",
+		"aes128-ctr,3des-cbc",
+		"hmac-sha2-256,hmac-md5",
+		"hmac-sha2-256",
+		"none",
+		"none,zlib@openssh.com",
+		"",
+		"",
+	})
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(server)))
+	info, err := det.SSHAlgorithmCheck("ssh.test", "22")
+	if err != nil {
+		t.Fatalf("SSH algorithm check failed: %v", err)
+	}
+	if strings.Join(info.Ciphers, ",") != "aes128-ctr,aes128-cbc,3des-cbc" {
+		t.Errorf("Unexpected ciphers: %v", info.Ciphers)
+	}
+	if strings.Join(info.Compression, ",") != "none,zlib@openssh.com" {
+		t.Errorf("Unexpected compression: %v", info.Compression)
+	}
+	expectedWeak := "kex:diffie-hellman-group1-sha1,hostkey:ssh-dss,cipher:aes128-cbc,cipher:3des-cbc,mac:hmac-md5"
+	if strings.Join(info.Weak, ",") != expectedWeak {
+		t.Errorf("Unexpected weak algorithms: %v", info.Weak)
+	}
+
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		conn.Write([]byte("RFB 003.008\n"))
+	})))
+	if _, err := det.SSHAlgorithmCheck("vnc.test", "5900"); err != custom_error.ErrSSHNotFound {
+		t.Errorf("Expected ErrSSHNotFound for VNC server, got %v", err)
+	}
+}
+
+func TestDetector_SSHAuthMethodsCheck(t *testing.T) {
+	server, _ := sshTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			t.Errorf("Unexpected password attempt %q", password)
+			return nil, errors.New("denied")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, errors.New("denied")
+		},
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			client(conn.User(), "", []string{"Password: "}, []bool{false})
+			return nil, errors.New("denied")
+		},
+	})
+	host, port := startTCPServer(t, server)
+	det := NewDetector(WithTimeout(time.Second))
+	info, err := det.SSHAuthMethodsCheck(host, port)
+	if err != nil {
+		t.Fatalf("SSH auth methods check failed: %v", err)
+	}
+	if strings.Join(info.Methods, ",") != "publickey,password,keyboard-interactive" || !info.PasswordEnabled {
+		t.Errorf("Unexpected auth info %+v", info)
+	}
+
+	server, _ = sshTestServer(t, &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, errors.New("denied")
+		},
+	})
+	host, port = startTCPServer(t, server)
+	info, err = det.SSHAuthMethodsCheck(host, port)
+	if err != nil {
+		t.Fatalf("SSH auth methods check failed: %v", err)
+	}
+	if strings.Join(info.Methods, ",") != "publickey" || info.PasswordEnabled {
+		t.Errorf("Unexpected auth info %+v", info)
+	}
+
+	// RHEL 默认配置：声明 gssapi-with-mic，不允许 keyboard-interactive，主机密钥在同一连接上获取
+	server, hostKey := sshTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			t.Errorf("Unexpected password attempt %q", password)
+			return nil, errors.New("denied")
+		},
+		GSSAPIWithMICConfig: &ssh.GSSAPIWithMICConfig{
+			AllowLogin: func(conn ssh.ConnMetadata, srcName string) (*ssh.Permissions, error) {
+				return nil, errors.New("denied")
+			},
+			Server: testGSSAPIServer{},
+		},
+	})
+	host, port = startTCPServer(t, server)
+	info, err = det.SSHAuthMethodsCheck(host, port)
+	if err != nil {
+		t.Fatalf("SSH auth methods check failed: %v", err)
+	}
+	if strings.Join(info.Methods, ",") != "password,gssapi-with-mic" || !info.PasswordEnabled {
+		t.Errorf("Unexpected auth info %+v", info)
+	}
+	if info.HostKey == nil || info.HostKey.SHA256 != ssh.FingerprintSHA256(hostKey) {
+		t.Errorf("Expected host key captured during the auth probe, got %+v", info.HostKey)
+	}
+}
+
+// testGSSAPIServer 拒绝所有 GSSAPI 令牌
+type testGSSAPIServer struct{}
+
+func (testGSSAPIServer) AcceptSecContext(token []byte) ([]byte, string, bool, error) {
+	return nil, "", false, errors.New("denied")
+}
+
+func (testGSSAPIServer) VerifyMIC(micField []byte, micToken []byte) error {
+	return errors.New("denied")
+}
+
+func (testGSSAPIServer) DeleteSecContext() error {
+	return nil
+}
+
+func TestDetector_SSHBannerCheck(t *testing.T) {
+	tests := []struct {
+		banner   string
+		expected SSHBannerInfo
+	}{
+		{"SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n", SSHBannerInfo{ProtocolVersion: "2.0", Software: "OpenSSH", SoftwareVersion: "8.9p1", Comments: "Ubuntu-3ubuntu0.6", OS: "Ubuntu"}},
+		{"SSH-2.0-OpenSSH_for_Windows_8.1\r\n", SSHBannerInfo{ProtocolVersion: "2.0", Software: "OpenSSH", SoftwareVersion: "8.1", OS: "Windows"}},
+		{"SSH-2.0-dropbear_2020.81\r\n", SSHBannerInfo{ProtocolVersion: "2.0", Software: "dropbear", SoftwareVersion: "2020.81"}},
+		{"SSH-2.0-libssh-0.6.0\r\n", SSHBannerInfo{ProtocolVersion: "2.0", Software: "libssh", SoftwareVersion: "0.6.0"}},
+		{"SSH-1.99-Cisco-1.25\r\n", SSHBannerInfo{ProtocolVersion: "1.99", Software: "Cisco", SoftwareVersion: "1.25"}},
+		{"SSH-2.0-ROSSSH\r\n", SSHBannerInfo{ProtocolVersion: "2.0", Software: "RouterOS", OS: "RouterOS"}},
+	}
+	for _, tt := range tests {
+		det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+			go io.Copy(io.Discard, conn)
+			conn.Write([]byte(tt.banner))
+			time.Sleep(50 * time.Millisecond)
+		})))
+		info, err := det.SSHBannerCheck("ssh.test", "22")
+		if err != nil {
+			t.Errorf("%q: SSH banner check failed: %v", tt.banner, err)
+			continue
+		}
+		tt.expected.Raw = strings.TrimRight(tt.banner, "\r\n")
+		if *info != tt.expected {
+			t.Errorf("%q: got %+v, want %+v", tt.banner, *info, tt.expected)
+		}
+	}
+
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("220 ProFTPD Server ready.\r\n"))
+	})))
+	if _, err := det.SSHBannerCheck("ftp.test", "21"); err != custom_error.ErrSSHNotFound {
+		t.Errorf("Expected ErrSSHNotFound for FTP server, got %v", err)
+	}
+}
+
+func TestDetector_SFTPCheckWithFallback(t *testing.T) {
+	server, _ := sshTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "audit" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	})
+	host, port := startTCPServer(t, server)
+	det := NewDetector(WithTimeout(2 * time.Second))
+
+	// 服务端拒绝 "none" 认证，只能确认是 SSH
+	if err := det.SFTPCheck(host, port, "", "", ""); err != custom_error.ErrSFTPAuthRequired {
+		t.Errorf("Expected ErrSFTPAuthRequired, got %v", err)
+	}
+	diagnostics, err := det.SFTPCheckWithFallback(host, port, NewSSHAuthOptions("", "", ""))
+	if err != custom_error.ErrSFTPAuthRequired || diagnostics.Status != SFTPStatusUnknown {
+		t.Errorf("Expected auth required without credentials, got %v (%s)", err, diagnostics.Status)
+	}
+
+	diagnostics, err = det.SFTPCheckWithFallback(host, port, NewSSHAuthOptions("audit", "wrong", ""))
+	if err != custom_error.ErrSFTPAuthRequired || diagnostics.Status != SFTPStatusUnknown {
+		t.Errorf("Expected auth required with wrong password, got %v (%s)", err, diagnostics.Status)
+	}
+
+	diagnostics, err = det.SFTPCheckWithFallback(host, port, NewSSHAuthOptions("audit", "secret", ""))
+	if err != nil || diagnostics.Status != SFTPStatusConfirmed {
+		t.Errorf("Expected SFTP confirmed after login, got %v (%s: %s)", err, diagnostics.Status, diagnostics.ErrorMsg)
+	}
+
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("220 ProFTPD Server ready.\r\n"))
+	})))
+	diagnostics, err = det.SFTPCheckWithFallback("ftp.test", "21", NewSSHAuthOptions("audit", "secret", ""))
+	if err != custom_error.ErrSFTPNotFound || diagnostics.Status != SFTPStatusNotFound {
+		t.Errorf("Expected ErrSFTPNotFound for FTP server, got %v (%s)", err, diagnostics.Status)
+	}
+
+	// 密钥交换失败不是需要认证，报告实际的握手错误
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1\r\n"))
+		time.Sleep(50 * time.Millisecond)
+	})))
+	diagnostics, err = det.SFTPCheckWithDiagnostics("ssh.test", "22")
+	if err == nil || err == custom_error.ErrSFTPAuthRequired || diagnostics.Status != SFTPStatusNotFound {
+		t.Errorf("Expected handshake failure, got %v (%s)", err, diagnostics.Status)
+	}
+	if !strings.Contains(diagnostics.ErrorMsg, "SSH握手失败") {
+		t.Errorf("Expected the handshake error in diagnostics, got %q", diagnostics.ErrorMsg)
+	}
+}
+
+func TestScanTools_SFTPAuthRequired(t *testing.T) {
+	server, _ := sshTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, errors.New("denied")
+		},
+	})
+	host, port := startTCPServer(t, server)
+
+	// 需要认证的 SSH 服务作为结果报告，附带 sftp_status、banner 和主机密钥
+	scanTools := NewScanTools(1, 2*time.Second)
+	metadata, err := scanTools.runCheck(SFTP, DeliveryInfo{Detector: NewDetector(WithTimeout(2 * time.Second)), Host: host, Port: port})
+	if err != nil {
+		t.Fatalf("Expected auth required host to be reported, got %v", err)
+	}
+	if metadata["sftp_status"] != string(SFTPStatusUnknown) || metadata["ssh_software"] != "Go" || metadata["ssh_hostkey_sha256"] == "" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+}
+
+func TestScanTools_SFTPAuditSingleLogin(t *testing.T) {
+	var logins int32
+	server, _ := sshTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			atomic.AddInt32(&logins, 1)
+			if conn.User() == "audit" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	})
+	host, port := startTCPServer(t, server)
+
+	// 同时开启登录确认和能力审计时，每个目标只登录一次
+	scanTools := NewScanTools(1, 2*time.Second)
+	scanTools.SetSFTPAuthFallback(true)
+	scanTools.SetSFTPAudit(true, false)
+	deliveryInfo := DeliveryInfo{Detector: NewDetector(WithTimeout(2 * time.Second)), Host: host, Port: port, User: "audit", Password: "secret"}
+	metadata, err := scanTools.runCheck(SFTP, deliveryInfo)
+	if err != nil {
+		t.Fatalf("SFTP audit scan failed: %v", err)
+	}
+	if metadata["sftp_status"] != string(SFTPStatusConfirmed) || metadata["sftp_version"] != "3" {
+		t.Errorf("Expected SFTP confirmed by the audit login, got %v", metadata)
+	}
+	if n := atomic.LoadInt32(&logins); n != 1 {
+		t.Errorf("Expected 1 login, got %d", n)
+	}
+
+	// 审计登录失败时仍然报告 auth_required
+	deliveryInfo.Password = "wrong"
+	metadata, err = scanTools.runCheck(SFTP, deliveryInfo)
+	if err != nil || metadata["sftp_status"] != string(SFTPStatusUnknown) {
+		t.Errorf("Expected auth required after failed audit login, got %v (%v)", metadata, err)
+	}
+	if _, ok := metadata["sftp_version"]; ok {
+		t.Errorf("Unexpected audit metadata %v", metadata)
+	}
+}
+
+func TestDetector_SFTPAudit(t *testing.T) {
+	config := func() *ssh.ServerConfig {
+		return &ssh.ServerConfig{
+			PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+				if conn.User() == "audit" && string(password) == "secret" {
+					return nil, nil
+				}
+				return nil, errors.New("denied")
+			},
+		}
+	}
+	server, _ := sshTestServer(t, config())
+	host, port := startTCPServer(t, server)
+	det := NewDetector(WithTimeout(2 * time.Second))
+
+	if _, err := det.SFTPAudit(host, port, NewSSHAuthOptions("audit", "", ""), false); err == nil {
+		t.Error("Expected error without credentials")
+	}
+	if _, err := det.SFTPAudit(host, port, NewSSHAuthOptions("audit", "wrong", ""), false); err == nil {
+		t.Error("Expected error with wrong password")
+	}
+
+	// 只读服务端
+	info, err := det.SFTPAudit(host, port, NewSSHAuthOptions("audit", "secret", ""), true)
+	if err != nil {
+		t.Fatalf("SFTP audit failed: %v", err)
+	}
+	if info.ProtocolVersion != 3 {
+		t.Errorf("Expected sftp version 3, got %d", info.ProtocolVersion)
+	}
+	if info.ExtensionData["posix-rename@openssh.com"] != "1" || info.ExtensionData["statvfs@openssh.com"] != "2" {
+		t.Errorf("Unexpected extensions %v", info.ExtensionData)
+	}
+	if info.HomeDir == "" || info.Chrooted {
+		t.Errorf("Unexpected home %q, chrooted %v", info.HomeDir, info.Chrooted)
+	}
+	if !info.WriteTested || info.Writable {
+		t.Errorf("Expected read-only server to be reported not writable: %+v", info)
+	}
+	metadata := info.Metadata()
+	if metadata["sftp_version"] != "3" || !strings.Contains(metadata["sftp_extensions"], "posix-rename@openssh.com=1") || metadata["sftp_writable"] != "false" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+
+	// 可写服务端，测试文件必须被删除
+	dir := t.TempDir()
+	server, _ = sshTestServer(t, config(), sftp.WithServerWorkingDirectory(dir))
+	host, port = startTCPServer(t, server)
+	info, err = det.SFTPAudit(host, port, NewSSHAuthOptions("audit", "secret", ""), true)
+	if err != nil {
+		t.Fatalf("SFTP audit failed: %v", err)
+	}
+	if info.HomeDir != dir || !info.Writable || info.WriteLeftover != "" {
+		t.Errorf("Expected writable home %s: %+v", dir, info)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Write test left %d files behind", len(entries))
+	}
+
+	// 不开启写入测试时不报告是否可写
+	info, err = det.SFTPAudit(host, port, NewSSHAuthOptions("audit", "secret", ""), false)
+	if err != nil {
+		t.Fatalf("SFTP audit failed: %v", err)
+	}
+	if _, ok := info.Metadata()["sftp_writable"]; ok || info.WriteTested {
+		t.Errorf("Write test should be opt-in: %+v", info)
+	}
+}
+
+// writeTestKey 生成 ed25519 私钥写入 dir/name，返回私钥
+func writeTestKey(t *testing.T, dir, name string) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, name)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return key
+}
+
+func TestDetector_SFTPCheckWithAuthOptions(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, "id_other")
+	userKey := writeTestKey(t, dir, "id_user")
+	userPub, _ := ssh.NewPublicKey(userKey.Public())
+
+	// id_cert 本身不被信任，只能通过 CA 签发的证书登录
+	certKey := writeTestKey(t, dir, "id_cert")
+	certPub, _ := ssh.NewPublicKey(certKey.Public())
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	caSigner, _ := ssh.NewSignerFromKey(caKey)
+	cert := &ssh.Certificate{
+		Key:             certPub,
+		CertType:        ssh.UserCert,
+		KeyId:           "audit",
+		ValidPrincipals: []string{"audit"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		t.Fatalf("Failed to sign certificate: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "id_cert-cert.pub"), ssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	certChecker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), caSigner.PublicKey().Marshal())
+		},
+		UserKeyFallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), userPub.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	server, _ := sshTestServer(t, &ssh.ServerConfig{
+		PublicKeyCallback: certChecker.Authenticate,
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := client("", "", []string{"Password: "}, []bool{false})
+			if err == nil && len(answers) == 1 && answers[0] == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	})
+	host, port := startTCPServer(t, server)
+	det := NewDetector(WithTimeout(2 * time.Second))
+
+	key := func(name string) string { return filepath.Join(dir, name) }
+	tests := []struct {
+		name    string
+		auth    SSHAuthOptions
+		wantErr bool
+	}{
+		{"untrusted key", SSHAuthOptions{User: "audit", PrivateKeys: []string{key("id_other")}}, true},
+		{"keys tried in order", SSHAuthOptions{User: "audit", PrivateKeys: []string{key("id_other"), key("id_user")}}, false},
+		{"openssh certificate", SSHAuthOptions{User: "audit", PrivateKeys: []string{key("id_cert")}}, false},
+		{"password only", SSHAuthOptions{User: "audit", Password: "secret"}, true},
+		{"keyboard-interactive", SSHAuthOptions{User: "audit", Password: "secret", KeyboardInteractive: true}, false},
+		{"missing key file", SSHAuthOptions{User: "audit", PrivateKeys: []string{key("id_missing")}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := det.SFTPCheckWithAuthOptions(host, port, tt.auth)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SFTPCheckWithAuthOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("ssh agent", func(t *testing.T) {
+		keyring := agent.NewKeyring()
+		if err := keyring.Add(agent.AddedKey{PrivateKey: userKey}); err != nil {
+			t.Fatalf("Failed to add key to agent: %v", err)
+		}
+		socket := filepath.Join(dir, "agent.sock")
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Skipf("Unix sockets not available: %v", err)
+		}
+		defer listener.Close()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go agent.ServeAgent(keyring, conn)
+			}
+		}()
+
+		auth := SSHAuthOptions{User: "audit", UseAgent: true, AgentSocket: socket}
+		if err := det.SFTPCheckWithAuthOptions(host, port, auth); err != nil {
+			t.Errorf("Expected login with agent key, got %v", err)
+		}
+		info, err := det.SFTPAudit(host, port, auth, false)
+		if err != nil || info.ProtocolVersion != 3 {
+			t.Errorf("Expected audit with agent key, got %v", err)
+		}
+	})
+
+	t.Run("broken ssh agent", func(t *testing.T) {
+		socket := filepath.Join(dir, "broken.sock")
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Skipf("Unix sockets not available: %v", err)
+		}
+		defer listener.Close()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+
+		// agent 不可用和 agent 中没有密钥要能区分
+		auth := SSHAuthOptions{User: "audit", UseAgent: true, AgentSocket: socket}
+		if _, err := det.SFTPAudit(host, port, auth, false); err == nil || !strings.Contains(err.Error(), "ssh agent") {
+			t.Errorf("Expected the ssh agent error to be reported, got %v", err)
+		}
+	})
+
+	t.Run("scan credentials", func(t *testing.T) {
+		// id_user_enc 是用 secret 加密的 id_user
+		block, err := ssh.MarshalPrivateKeyWithPassphrase(userKey, "id_user_enc", []byte("secret"))
+		if err != nil {
+			t.Fatalf("Failed to marshal encrypted key: %v", err)
+		}
+		if err := os.WriteFile(key("id_user_enc"), pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("Failed to write key: %v", err)
+		}
+
+		tests := []struct {
+			name                string
+			keyPath             string
+			keyboardInteractive bool
+		}{
+			{"untrusted unencrypted key with password", key("id_other"), true},
+			{"encrypted key decrypted with password", key("id_user_enc"), false},
+			{"missing key with password", key("id_missing"), true},
+		}
+		for _, tt := range tests {
+			scanTools := NewScanTools(1, 2*time.Second)
+			scanTools.SetSSHAuth(SSHAuthOptions{KeyboardInteractive: tt.keyboardInteractive})
+			auth := scanTools.sftpAuth(DeliveryInfo{User: "audit", Password: "secret", PrivateKeyFullPath: tt.keyPath})
+			if err := det.SFTPCheckWithAuthOptions(host, port, auth); err != nil {
+				t.Errorf("%s: expected login, got %v", tt.name, err)
+			}
+		}
+	})
+}
+
+// ftpTestServer 模拟 FTP 服务端，anonymous 为 true 时允许匿名登录，tlsConfig 不为 nil 时支持 AUTH TLS
+func ftpTestServer(greeting string, anonymous bool, tlsConfig *tls.Config) func(net.Conn) {
+	return func(conn net.Conn) {
+		conn.Write([]byte(greeting))
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.TrimSpace(line); {
+			case cmd == "FEAT":
+				conn.Write([]byte("211-Features:\r\n UTF8\r\n MLST type*;size*;modify*;\r\n EPSV\r\n AUTH TLS\r\n211 End\r\n"))
+			case cmd == "SYST":
+				conn.Write([]byte("215 UNIX Type: L8\r\n"))
+			case cmd == "USER anonymous":
+				conn.Write([]byte("331 Please specify the password.\r\n"))
+			case strings.HasPrefix(cmd, "PASS ") && anonymous:
+				conn.Write([]byte("230 Login successful.\r\n"))
+			case strings.HasPrefix(cmd, "PASS "):
+				conn.Write([]byte("530 Login incorrect.\r\n"))
+			case cmd == "AUTH TLS" && tlsConfig != nil:
+				conn.Write([]byte("234 Proceed with negotiation.\r\n"))
+				conn = tls.Server(conn, tlsConfig)
+				reader = bufio.NewReader(conn)
+			case cmd == "QUIT":
+				conn.Write([]byte("221 Goodbye.\r\n"))
+				return
+			default:
+				conn.Write([]byte("502 Command not implemented.\r\n"))
+			}
+		}
+	}
+}
+
+func TestDetector_FTPCapabilitiesCheck(t *testing.T) {
+	greeting := "220-Welcome to the test FTP service.\r\n220-Unauthorized access is prohibited.\r\n220 (vsFTPd 3.0.5)\r\n"
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(ftpTestServer(greeting, true, nil))))
+	info, err := det.FTPCapabilitiesCheck("ftp.test", "21", false)
+	if err != nil {
+		t.Fatalf("FTP capabilities check failed: %v", err)
+	}
+	if len(info.Greeting) != 3 || info.Greeting[2] != "(vsFTPd 3.0.5)" {
+		t.Errorf("Unexpected greeting %q", info.Greeting)
+	}
+	if info.System != "UNIX Type: L8" {
+		t.Errorf("Unexpected system %q", info.System)
+	}
+	if !info.UTF8 || !info.MLST || !info.EPSV || !info.AuthTLS || len(info.Features) != 4 {
+		t.Errorf("Unexpected features %+v", info)
+	}
+	if _, ok := info.Metadata()["ftp_anonymous"]; ok || info.AnonymousTested {
+		t.Error("Anonymous login should be opt-in")
+	}
+
+	info, err = det.FTPCapabilitiesCheck("ftp.test", "21", true)
+	if err != nil || !info.AnonymousAllowed || info.Metadata()["ftp_anonymous"] != "true" {
+		t.Errorf("Expected anonymous access, got %+v (%v)", info, err)
+	}
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(ftpTestServer("220 FTP ready\r\n", false, nil))))
+	info, err = det.FTPCapabilitiesCheck("ftp.test", "21", true)
+	if err != nil || info.AnonymousAllowed || info.Metadata()["ftp_anonymous"] != "false" {
+		t.Errorf("Expected anonymous access to be denied, got %+v (%v)", info, err)
+	}
+
+	// 不支持 FEAT 的服务端
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		conn.Write([]byte("220 Old FTP\r\n"))
+		reader := bufio.NewReader(conn)
+		for {
+			if _, err := reader.ReadString('\n'); err != nil {
+				return
+			}
+			conn.Write([]byte("500 Unknown command.\r\n"))
+		}
+	})))
+	info, err = det.FTPCapabilitiesCheck("ftp.test", "21", false)
+	if err != nil || len(info.Features) != 0 || info.System != "" {
+		t.Errorf("Expected FTP without features, got %+v (%v)", info, err)
+	}
+
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1\r\n"))
+	})))
+	if _, err := det.FTPCapabilitiesCheck("ftp.test", "21", false); err != custom_error.ErrFTPNotFound {
+		t.Errorf("Expected ErrFTPNotFound for SSH server, got %v", err)
+	}
+}
+
+func TestDetector_FTPSCheck(t *testing.T) {
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{generateTestCertificate(t, "ftp.test")}}
+
+	// explicit：AUTH TLS 升级
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(ftpTestServer("220 FTP ready\r\n", false, tlsConfig))))
+	info, err := det.FTPSExplicitCheck("ftp.test", "21")
+	if err != nil {
+		t.Fatalf("Explicit FTPS check failed: %v", err)
+	}
+	if info.Mode != "explicit" || info.TLSVersion != "TLS 1.3" || info.CipherSuite == "" {
+		t.Errorf("Unexpected TLS parameters %+v", info)
+	}
+	if info.Certificate == nil || info.Certificate.SubjectCN != "ftp.test" || !info.Certificate.SelfSigned {
+		t.Errorf("Unexpected certificate %+v", info.Certificate)
+	}
+	if metadata := info.Metadata(); metadata["ftps_cert_subject_cn"] != "ftp.test" || metadata["ftps_tls_version"] != "TLS 1.3" {
+		t.Errorf("Unexpected metadata %v", metadata)
+	}
+
+	// 只支持明文 FTP
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(ftpTestServer("220 FTP ready\r\n", false, nil))))
+	if _, err := det.FTPSExplicitCheck("ftp.test", "21"); err != custom_error.ErrFTPSNotFound {
+		t.Errorf("Expected ErrFTPSNotFound for plain FTP, got %v", err)
+	}
+	if _, err := det.FTPSImplicitCheck("ftp.test", "990"); err != custom_error.ErrFTPSNotFound {
+		t.Errorf("Expected ErrFTPSNotFound for implicit check on plain FTP, got %v", err)
+	}
+
+	// implicit：连接后直接 TLS
+	plain := ftpTestServer("220-Implicit FTPS\r\n220 ready\r\n", false, nil)
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		plain(tls.Server(conn, tlsConfig))
+	})))
+	info, err = det.FTPSImplicitCheck("ftp.test", "990")
+	if err != nil {
+		t.Fatalf("Implicit FTPS check failed: %v", err)
+	}
+	if info.Mode != "implicit" || len(info.Greeting) != 2 || info.Certificate == nil {
+		t.Errorf("Unexpected implicit FTPS result %+v", info)
+	}
+
+	if ports := GetProtocolDefaultPorts(FTPS); len(ports) != 1 || ports[0] != 990 {
+		t.Errorf("Unexpected FTPS default ports %v", ports)
+	}
+	if String2ProtocolType("ftps") != FTPS || FTPS.String() != "ftps" {
+		t.Error("FTPS protocol type is not registered")
+	}
+}
+
+// vncTestServer 模拟 VNC 服务端：发送 serverVersion，记录客户端回复的版本后发送 security
+func vncTestServer(serverVersion string, security []byte, clientVersion chan<- string) func(net.Conn) {
+	return func(conn net.Conn) {
+		conn.Write([]byte("RFB " + serverVersion + "\n"))
+		reply := make([]byte, 12)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return
+		}
+		clientVersion <- string(reply)
+		conn.Write(security)
+	}
+}
+
+func TestDetector_VNCSecurityCheck(t *testing.T) {
+	failure := append([]byte{0, 0, 0, 0, 0, 0, 0, 20}, []byte("Too many connections")...)
+	tests := []struct {
+		name          string
+		serverVersion string
+		security      []byte
+		clientVersion string
+		names         string
+		noAuth        bool
+		reason        string
+	}{
+		{"no auth", "003.008", []byte{2, 1, 2}, "RFB 003.008\n", "None,VNC Authentication", true, ""},
+		{"vnc auth", "003.008", []byte{3, 2, 16, 19}, "RFB 003.008\n", "VNC Authentication,Tight,VeNCrypt", false, ""},
+		{"apple", "003.889", []byte{2, 30, 2}, "RFB 003.008\n", "Apple Remote Desktop,VNC Authentication", false, ""},
+		{"version 3.7", "003.007", []byte{1, 1}, "RFB 003.007\n", "None", true, ""},
+		{"version 3.3", "003.003", []byte{0, 0, 0, 1}, "RFB 003.003\n", "None", true, ""},
+		{"version 3.5", "003.005", []byte{0, 0, 0, 2}, "RFB 003.003\n", "VNC Authentication", false, ""},
+		{"refused", "003.008", append([]byte{0}, failure[4:]...), "RFB 003.008\n", "", false, "Too many connections"},
+		{"refused 3.3", "003.003", failure, "RFB 003.003\n", "", false, "Too many connections"},
+		{"unknown type", "003.008", []byte{1, 200}, "RFB 003.008\n", "Unknown(200)", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientVersion := make(chan string, 1)
+			det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(vncTestServer(tt.serverVersion, tt.security, clientVersion))))
+			info, err := det.VNCSecurityCheck("vnc.test", "5900")
+			if err != nil {
+				t.Fatalf("VNC security check failed: %v", err)
+			}
+			if reply := <-clientVersion; reply != tt.clientVersion {
+				t.Errorf("Expected client version %q, got %q", tt.clientVersion, reply)
+			}
+			metadata := info.Metadata()
+			if info.ServerVersion != tt.serverVersion || metadata["vnc_version"] != tt.serverVersion {
+				t.Errorf("Unexpected server version %q", info.ServerVersion)
+			}
+			if metadata["vnc_security_types"] != tt.names {
+				t.Errorf("Expected security types %q, got %q", tt.names, metadata["vnc_security_types"])
+			}
+			if info.NoAuth != tt.noAuth || metadata["vnc_no_auth"] != strconv.FormatBool(tt.noAuth) {
+				t.Errorf("Expected no auth %v, got %v", tt.noAuth, info.NoAuth)
+			}
+			if info.FailureReason != tt.reason || metadata["vnc_failure_reason"] != tt.reason {
+				t.Errorf("Expected failure reason %q, got %q", tt.reason, info.FailureReason)
+			}
+		})
+	}
+
+	// 只发送 ProtocolVersion 就断开的服务端仍然是 VNC
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		conn.Write([]byte("RFB 003.008\n"))
+	})))
+	info, err := det.VNCSecurityCheck("vnc.test", "5900")
+	if err != nil || info.ServerVersion != "003.008" || len(info.SecurityTypes) != 0 {
+		t.Errorf("Expected VNC found without security types, got %+v (%v)", info, err)
+	}
+
+	// 读取超时为 0 表示不设置截止时间，而不是立即超时
+	det = NewDetector(WithTimeout(time.Second), WithReadTimeout(0), WithDialContext(pipeDialer(vncTestServer("003.008", []byte{1, 2}, make(chan string, 1)))))
+	if info, err := det.VNCSecurityCheck("vnc.test", "5900"); err != nil || len(info.SecurityTypes) != 1 || info.SecurityTypes[0] != 2 {
+		t.Errorf("Expected VNC found without read timeout, got %+v (%v)", info, err)
+	}
+
+	for _, banner := range []string{"RFB 003.008", "RFB abc.def\n", "SSH-2.0-OpenSSH\r\n"} {
+		det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+			conn.Write([]byte(banner))
+		})))
+		if _, err := det.VNCSecurityCheck("vnc.test", "5900"); err != custom_error.ErrVNCNotFound {
+			t.Errorf("Expected ErrVNCNotFound for %q, got %v", banner, err)
+		}
+		if err := det.VNCCheck("vnc.test", "5900"); err != custom_error.ErrVNCNotFound {
+			t.Errorf("Expected ErrVNCNotFound from VNCCheck for %q, got %v", banner, err)
+		}
+	}
+}
+
+// telnetTestServer 模拟 Telnet 服务端：先发送 negotiation，再发送 banner，丢弃客户端的回复
+func telnetTestServer(negotiation []byte, banner string) func(net.Conn) {
+	return func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write(negotiation)
+		conn.Write([]byte(banner))
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestDetector_TelnetBannerCheck(t *testing.T) {
+	const (
+		iac  = 255
+		will = 251
+		do   = 253
+		sb   = 250
+		se   = 240
+	)
+	negotiation := []byte{iac, do, 24, iac, do, 31, iac, will, 1, iac, will, 3, iac, do, 24,
+		iac, sb, 24, 1, iac, se, iac, 241}
+	tests := []struct {
+		name        string
+		negotiation []byte
+		banner      string
+		do          string
+		will        string
+		prompt      string
+		device      string
+	}{
+		{"cisco", negotiation, "\r\n\r\nUser Access Verification\r\n\r\nUsername: ", "TERMINAL-TYPE,NAWS", "ECHO,SUPPRESS-GO-AHEAD", "Username:", "Cisco IOS"},
+		{"busybox", []byte{iac, do, 1}, "\r\nBusyBox v1.31.1 () built-in shell (ash)\r\n\r\n# ", "ECHO", "", "#", "BusyBox"},
+		{"windows", []byte{iac, do, 37, iac, will, 0}, "Welcome to Microsoft Telnet Service \r\n\n\rlogin: ", "AUTHENTICATION", "BINARY", "login:", "Windows Telnet Server"},
+		{"mikrotik", nil, "MikroTik v6.49.7 (stable)\r\nLogin: ", "", "", "Login:", "MikroTik RouterOS"},
+		{"unknown", []byte{iac, will, 200}, "Debian GNU/Linux 12\r\nhost login: ", "", "200", "host login:", ""},
+		{"no prompt", []byte{iac, do, 24}, "", "TERMINAL-TYPE", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(telnetTestServer(tt.negotiation, tt.banner))))
+			info, err := det.TelnetBannerCheck("telnet.test", "23")
+			if err != nil {
+				t.Fatalf("Telnet banner check failed: %v", err)
+			}
+			metadata := info.Metadata()
+			if metadata["telnet_do"] != tt.do || metadata["telnet_will"] != tt.will {
+				t.Errorf("Unexpected options do=%q will=%q", metadata["telnet_do"], metadata["telnet_will"])
+			}
+			if info.Prompt != tt.prompt || metadata["telnet_prompt"] != tt.prompt {
+				t.Errorf("Expected prompt %q, got %q", tt.prompt, info.Prompt)
+			}
+			if info.Device != tt.device || metadata["telnet_device"] != tt.device {
+				t.Errorf("Expected device %q, got %q", tt.device, info.Device)
+			}
+			if strings.ContainsAny(info.Banner, "\r\x00") || strings.Contains(metadata["telnet_banner"], "\n") {
+				t.Errorf("Banner should not contain control characters: %q", info.Banner)
+			}
+		})
+	}
+
+	// 登录提示之后服务端不再发送数据，不应该等到超时
+	det := NewDetector(WithTimeout(3*time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("\r\nUser Access Verification\r\n\r\nPassword: "))
+		time.Sleep(5 * time.Second)
+	})))
+	start := time.Now()
+	info, err := det.TelnetBannerCheck("telnet.test", "23")
+	if err != nil || info.Prompt != "Password:" {
+		t.Errorf("Expected password prompt, got %+v (%v)", info, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Banner capture should stop at the prompt, took %v", elapsed)
+	}
+}
+
+func TestDetector_TelnetFalsePositives(t *testing.T) {
+	mysqlHandshake := append([]byte{0x4a, 0, 0, 0, 0x0a}, []byte("8.0.36\x00")...)
+	mysqlHandshake = append(mysqlHandshake, make([]byte, 0x4a-7)...)
+	banners := map[string][]byte{
+		"ssh":            []byte("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n"),
+		"ftp":            []byte("220 (vsFTPd 3.0.5)\r\n"),
+		"ftp multi-line": []byte("220-Welcome\r\n220 Login: anonymous allowed\r\n"),
+		"smtp":           []byte("220 mail.example.com ESMTP Postfix\r\n"),
+		"pop3":           []byte("+OK Dovecot ready.\r\n"),
+		"imap":           []byte("* OK [CAPABILITY IMAP4rev1] Dovecot ready.\r\n"),
+		"http":           []byte("HTTP/1.1 400 Bad Request\r\n\r\n"),
+		"vnc":            []byte("RFB 003.008\n"),
+		"mysql":          mysqlHandshake,
+		"text":           []byte("hello world\r\n"),
+	}
+	for name, banner := range banners {
+		t.Run(name, func(t *testing.T) {
+			// 服务端发送欢迎信息后保持连接，等待客户端
+			server := func(conn net.Conn) {
+				go io.Copy(io.Discard, conn)
+				conn.Write(banner)
+				time.Sleep(3 * time.Second)
+			}
+			det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(server)))
+			start := time.Now()
+			if err := det.TelnetCheck("telnet.test", "23"); err != custom_error.ErrTelnetNotFound {
+				t.Errorf("Expected ErrTelnetNotFound, got %v", err)
+			}
+			if name != "text" && time.Since(start) > 500*time.Millisecond {
+				t.Errorf("Known protocol banner should be rejected without waiting, took %v", time.Since(start))
+			}
+			if _, err := det.TelnetBannerCheck("telnet.test", "23"); err != custom_error.ErrTelnetNotFound {
+				t.Errorf("Expected ErrTelnetNotFound from banner check, got %v", err)
+			}
+		})
+	}
+
+	servers := map[string]func(net.Conn){
+		"negotiation only": telnetTestServer([]byte{255, 253, 24}, ""),
+		"prompt only":      telnetTestServer(nil, "\r\nrouter login: "),
+		"shell prompt":     telnetTestServer(nil, "root@OpenWrt:~# "),
+	}
+	for name, server := range servers {
+		t.Run(name, func(t *testing.T) {
+			det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(server)))
+			if err := det.TelnetCheck("telnet.test", "23"); err != nil {
+				t.Errorf("Expected telnet found, got %v", err)
+			}
+		})
+	}
+}
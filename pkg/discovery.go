@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+)
+
+// DiscoveryOptions 主机发现阶段的配置
+type DiscoveryOptions struct {
+	Ports   []int // 用于 TCP 存活探测的常见端口
+	UseICMP bool  // 是否额外使用 ICMP Echo 探测（需要原始套接字权限）
+}
+
+// defaultDiscoveryPorts 存活探测默认使用的常见端口
+var defaultDiscoveryPorts = []int{22, 80, 443, 445, 3389, 135, 139, 21, 23, 5900}
+
+// maxDiscoveryTargetPorts 加入存活探测的扫描端口上限
+// 超过时跳过主机发现，因为扫描本身已经逐个探测这些端口，发现阶段省不下多少连接
+const maxDiscoveryTargetPorts = 128
+
+// NewDefaultDiscoveryOptions 返回默认的主机发现配置
+func NewDefaultDiscoveryOptions() *DiscoveryOptions {
+	ports := make([]int, len(defaultDiscoveryPorts))
+	copy(ports, defaultDiscoveryPorts)
+	return &DiscoveryOptions{
+		Ports:   ports,
+		UseICMP: true,
+	}
+}
+
+// SetDiscovery 设置主机发现配置，传入 nil 表示跳过主机发现，直接扫描所有主机
+func (s *ScanTools) SetDiscovery(options *DiscoveryOptions) {
+	s.discovery = options
+}
+
+// discoverHosts 对所有目标主机做存活探测，返回存活主机集合
+// 除了 DiscoveryOptions 中的常见端口，要扫描的端口也参与探测，只开放了这些端口的主机不会被漏掉
+// 未开启主机发现时返回 nil，调用方应当扫描所有主机
+func (s ScanTools) discoverHosts(d *Detector, ipRangeInfos []IPRangeInfo, targetPorts []int) (map[string]bool, error) {
+	if s.discovery == nil {
+		return nil, nil
+	}
+	probePorts := s.discovery.probePorts(targetPorts)
+	if probePorts == nil {
+		log.Printf("Host discovery skipped: %d target ports exceed the discovery limit", len(targetPorts))
+		return nil, nil
+	}
+
+	hosts, err := expandHosts(ipRangeInfos)
+	if err != nil {
+		return nil, err
+	}
+
+	aliveHosts := make(map[string]bool, 0)
+	var aliveMutex sync.Mutex
+	wg := &sync.WaitGroup{}
+	// 控制主机发现阶段的并发数
+	semaphore := make(chan struct{}, s.threads)
+
+	for _, host := range hosts {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(host string) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Recovered from panic in discovery goroutine: %v", r)
+				}
+				<-semaphore
+				wg.Done()
+			}()
+
+			if d.HostAliveCheck(host, probePorts, s.discovery.UseICMP) == nil {
+				aliveMutex.Lock()
+				aliveHosts[host] = true
+				aliveMutex.Unlock()
+			}
+		}(host)
+	}
+	wg.Wait()
+
+	log.Printf("Host discovery completed: %d/%d hosts alive", len(aliveHosts), len(hosts))
+	return aliveHosts, nil
+}
+
+// probePorts 合并发现端口和要扫描的端口并去重
+// 扫描端口超过 maxDiscoveryTargetPorts 时返回 nil，表示不做主机发现
+func (o DiscoveryOptions) probePorts(targetPorts []int) []int {
+	if len(targetPorts) > maxDiscoveryTargetPorts {
+		return nil
+	}
+	ports := make([]int, 0, len(o.Ports)+len(targetPorts))
+	seen := make(map[int]bool, cap(ports))
+	for _, port := range append(append([]int{}, o.Ports...), targetPorts...) {
+		if !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// expandHosts 将解析后的 IP 范围展开为主机列表
+// 注意这里会复制起始 IP，不修改 IPRangeInfo 本身
+func expandHosts(ipRangeInfos []IPRangeInfo) ([]string, error) {
+	hosts := make([]string, 0)
+	for _, ipRangeInfo := range ipRangeInfos {
+		if ipRangeInfo.CICR != nil {
+			err := ipRangeInfo.CICR.ForEachIP(func(ip string) error {
+				hosts = append(hosts, ip)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("expandHosts - ForEachIP error: %w", err)
+			}
+			continue
+		}
+
+		startIP := make(net.IP, len(ipRangeInfo.Begin))
+		copy(startIP, ipRangeInfo.Begin)
+		for i := 0; i < ipRangeInfo.CountNextTime; i++ {
+			if i != 0 {
+				startIP.To4()[3] += uint8(1)
+			}
+			hosts = append(hosts, startIP.String())
+		}
+	}
+	return hosts, nil
+}
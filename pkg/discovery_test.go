@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/allanpk716/go-protocol-detector/internal/utils"
+)
+
+// TestExpandHosts 测试主机展开不会修改原始的 IP 范围
+func TestExpandHosts(t *testing.T) {
+	scan := NewScanTools(10, 3*time.Second)
+
+	ipRanges, err := scan.parseHost("192.168.1.10-12,10.0.0.0/30")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing host: %v", err)
+	}
+
+	hosts, err := expandHosts(ipRanges)
+	if err != nil {
+		t.Fatalf("Unexpected error expanding hosts: %v", err)
+	}
+	if len(hosts) != 7 {
+		t.Fatalf("Expected 7 hosts, got %d: %v", len(hosts), hosts)
+	}
+	if hosts[0] != "192.168.1.10" || hosts[2] != "192.168.1.12" {
+		t.Errorf("Unexpected expanded hosts: %v", hosts)
+	}
+
+	// 原始的起始 IP 不应被修改
+	if ipRanges[0].Begin.String() != "192.168.1.10" {
+		t.Errorf("expandHosts modified range begin: %s", ipRanges[0].Begin.String())
+	}
+}
+
+// TestDetector_HostAliveCheck 测试本地监听端口和被拒绝的端口都判定为存活
+func TestDetector_HostAliveCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	openPort := listener.Addr().(*net.TCPAddr).Port
+
+	// 获取一个已关闭的端口，连接会被 RST 拒绝
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedPort := closedListener.Addr().(*net.TCPAddr).Port
+	closedListener.Close()
+
+	det := NewDetector(WithTimeout(timeOut))
+	if err := det.HostAliveCheck("127.0.0.1", []int{openPort}, false); err != nil {
+		t.Errorf("Expected host alive with open port, got %v", err)
+	}
+	if err := det.HostAliveCheck("127.0.0.1", []int{closedPort}, false); err != nil {
+		t.Errorf("Expected host alive with refused port, got %v", err)
+	}
+}
+
+// TestDiscoveryOptions_ProbePorts 测试要扫描的端口加入存活探测，端口过多时跳过主机发现
+func TestDiscoveryOptions_ProbePorts(t *testing.T) {
+	options := DiscoveryOptions{Ports: []int{22, 80}}
+
+	ports := options.probePorts([]int{80, 2222, 8080})
+	if len(ports) != 4 || ports[0] != 22 || ports[2] != 2222 || ports[3] != 8080 {
+		t.Errorf("Expected discovery and target ports merged, got %v", ports)
+	}
+	if len(options.Ports) != 2 {
+		t.Errorf("probePorts modified the discovery ports: %v", options.Ports)
+	}
+
+	tooMany := make([]int, maxDiscoveryTargetPorts+1)
+	for i := range tooMany {
+		tooMany[i] = 1000 + i
+	}
+	if ports := options.probePorts(tooMany); ports != nil {
+		t.Errorf("Expected discovery skipped for %d target ports, got %d probe ports", len(tooMany), len(ports))
+	}
+}
+
+// TestParseICMPEchoReply 测试解析带 IPv4 首部和不带首部的 ICMP Echo 应答
+func TestParseICMPEchoReply(t *testing.T) {
+	echoReply := []byte{0, 0, 0, 0, 0x12, 0x34, 0x00, 0x07, 'g', 'o'}
+	// IHL 为 6（24 字节，包含选项）的 IPv4 首部
+	ipHeader := make([]byte, 24)
+	ipHeader[0] = 0x46
+	ipHeader[9] = 1
+
+	for name, packet := range map[string][]byte{
+		"ipv4 framed": append(append([]byte{}, ipHeader...), echoReply...),
+		"icmp only":   echoReply,
+	} {
+		id, seq, ok := utils.ParseICMPEchoReply(packet)
+		if !ok || id != 0x1234 || seq != 7 {
+			t.Errorf("%s: Unexpected reply id=%#x seq=%d ok=%v", name, id, seq, ok)
+		}
+	}
+
+	// Echo 请求和截断的报文不是应答
+	request := append(append([]byte{}, ipHeader...), echoReply...)
+	request[24] = 8
+	if _, _, ok := utils.ParseICMPEchoReply(request); ok {
+		t.Error("Echo request should not be parsed as a reply")
+	}
+	if _, _, ok := utils.ParseICMPEchoReply(ipHeader[:10]); ok {
+		t.Error("Truncated packet should not be parsed as a reply")
+	}
+}
@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// HostKeyStatus 主机密钥与基线比较的结果
+type HostKeyStatus string
+
+const (
+	HostKeyNew       HostKeyStatus = "new"       // 基线中没有该目标，已记录
+	HostKeyUnchanged HostKeyStatus = "unchanged" // 与基线一致
+	HostKeyChanged   HostKeyStatus = "changed"   // 与基线不一致，可能是重装系统或者被仿冒
+)
+
+// HostKeyRecord 基线中记录的主机密钥
+type HostKeyRecord struct {
+	Type   string `json:"type"`
+	SHA256 string `json:"sha256"`
+}
+
+// HostKeyBaseline 持久化的 SSH 主机密钥基线，key 为 host:port
+// 发现密钥变化时不会覆盖基线中的记录，确认变化合理后需要手动删除该条记录或者基线文件
+type HostKeyBaseline struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]HostKeyRecord
+	dirty   bool
+}
+
+// LoadHostKeyBaseline 读取基线文件，文件不存在时返回空基线，扫描结束后由 Save 创建
+func LoadHostKeyBaseline(path string) (*HostKeyBaseline, error) {
+	baseline := &HostKeyBaseline{
+		path:    path,
+		records: make(map[string]HostKeyRecord),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return baseline, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read host key baseline: %w", err)
+	}
+	if err := json.Unmarshal(data, &baseline.records); err != nil {
+		return nil, fmt.Errorf("failed to parse host key baseline %s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// Check 将目标的主机密钥与基线比较，新目标会加入基线；变化时同时返回基线中的记录
+func (b *HostKeyBaseline) Check(target string, record HostKeyRecord) (HostKeyStatus, HostKeyRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous, ok := b.records[target]
+	if !ok {
+		b.records[target] = record
+		b.dirty = true
+		return HostKeyNew, record
+	}
+	if previous != record {
+		return HostKeyChanged, previous
+	}
+	return HostKeyUnchanged, previous
+}
+
+// Save 有新记录时写回基线文件
+func (b *HostKeyBaseline) Save() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(b.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal host key baseline: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免中途退出损坏基线
+	tempPath := b.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temporary host key baseline: %w", err)
+	}
+	if err := os.Rename(tempPath, b.path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename temporary host key baseline: %w", err)
+	}
+	b.dirty = false
+	return nil
+}
+
+// annotate 比较后把结果写入扫描结果的附加信息
+func (b *HostKeyBaseline) annotate(target string, metadata map[string]string) {
+	if metadata["ssh_hostkey_sha256"] == "" {
+		return
+	}
+	status, previous := b.Check(target, HostKeyRecord{
+		Type:   metadata["ssh_hostkey_type"],
+		SHA256: metadata["ssh_hostkey_sha256"],
+	})
+	metadata["ssh_hostkey_status"] = string(status)
+	if status == HostKeyChanged {
+		metadata["ssh_hostkey_previous"] = previous.Type + " " + previous.SHA256
+	}
+}
@@ -0,0 +1,260 @@
+package pkg
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTestServer 使用 x/crypto/ssh 模拟 SSH 服务端，session 通道上的 sftp 子系统由 pkg/sftp 服务端提供
+// config 为 nil 时不需要认证；没有指定 sftpOptions 时 sftp 服务端是只读的
+func sshTestServer(t *testing.T, config *ssh.ServerConfig, sftpOptions ...sftp.ServerOption) (func(net.Conn), ssh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	if config == nil {
+		config = &ssh.ServerConfig{NoClientAuth: true}
+	}
+	config.AddHostKey(signer)
+	if len(sftpOptions) == 0 {
+		sftpOptions = []sftp.ServerOption{sftp.ReadOnly()}
+	}
+	return func(conn net.Conn) {
+		serverConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		defer serverConn.Close()
+		go ssh.DiscardRequests(reqs)
+		for newChannel := range chans {
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go func() {
+				defer channel.Close()
+				for req := range requests {
+					isSFTP := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+					req.Reply(isSFTP, nil)
+					if isSFTP {
+						go func() {
+							if server, err := sftp.NewServer(channel, sftpOptions...); err == nil {
+								server.Serve()
+							}
+							channel.Close()
+						}()
+					}
+				}
+			}()
+		}
+	}, signer.PublicKey()
+}
+
+// startTCPServer 在本地端口上运行 handler，用于双方同时写数据、不适合 net.Pipe 的协议
+func startTCPServer(t *testing.T, handler func(net.Conn)) (string, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handler(conn)
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return host, port
+}
+
+func TestDetector_SSHHostKeyCheck(t *testing.T) {
+	server, hostKey := sshTestServer(t, nil)
+	host, port := startTCPServer(t, server)
+	det := NewDetector(WithTimeout(time.Second))
+	info, err := det.SSHHostKeyCheck(host, port)
+	if err != nil {
+		t.Fatalf("SSH host key check failed: %v", err)
+	}
+	if info.Type != ssh.KeyAlgoED25519 || info.SHA256 != ssh.FingerprintSHA256(hostKey) {
+		t.Errorf("Unexpected host key %+v", info)
+	}
+}
+
+func TestDetector_SFTPCheckWithDiagnostics(t *testing.T) {
+	server, hostKey := sshTestServer(t, nil)
+	host, port := startTCPServer(t, server)
+	det := NewDetector(WithTimeout(time.Second))
+	diagnostics, err := det.SFTPCheckWithDiagnostics(host, port)
+	if err != nil {
+		t.Fatalf("SFTP check failed: %v (%s)", err, diagnostics.ErrorMsg)
+	}
+	metadata := diagnostics.Metadata()
+	if metadata["ssh_software"] != "Go" || metadata["ssh_protocol"] != "2.0" {
+		t.Errorf("Unexpected banner metadata: %v", metadata)
+	}
+	if metadata["ssh_hostkey_sha256"] != ssh.FingerprintSHA256(hostKey) {
+		t.Errorf("Unexpected host key metadata: %v", metadata)
+	}
+}
+
+func TestHostKeyBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hostkeys.json")
+	baseline, err := LoadHostKeyBaseline(path)
+	if err != nil {
+		t.Fatalf("Failed to load missing baseline: %v", err)
+	}
+
+	original := HostKeyRecord{Type: "ssh-ed25519", SHA256: "SHA256:original"}
+	if status, _ := baseline.Check("10.0.0.1:22", original); status != HostKeyNew {
+		t.Errorf("Expected new, got %s", status)
+	}
+	if err := baseline.Save(); err != nil {
+		t.Fatalf("Failed to save baseline: %v", err)
+	}
+
+	baseline, err = LoadHostKeyBaseline(path)
+	if err != nil {
+		t.Fatalf("Failed to reload baseline: %v", err)
+	}
+	if status, _ := baseline.Check("10.0.0.1:22", original); status != HostKeyUnchanged {
+		t.Errorf("Expected unchanged, got %s", status)
+	}
+
+	metadata := map[string]string{"ssh_hostkey_type": "ssh-rsa", "ssh_hostkey_sha256": "SHA256:reimaged"}
+	baseline.annotate("10.0.0.1:22", metadata)
+	if metadata["ssh_hostkey_status"] != string(HostKeyChanged) {
+		t.Errorf("Expected changed, got %v", metadata)
+	}
+	if metadata["ssh_hostkey_previous"] != "ssh-ed25519 SHA256:original" {
+		t.Errorf("Unexpected previous key: %s", metadata["ssh_hostkey_previous"])
+	}
+	// 变化的密钥不会覆盖基线
+	if status, _ := baseline.Check("10.0.0.1:22", original); status != HostKeyUnchanged {
+		t.Errorf("Expected baseline to keep the original key, got %s", status)
+	}
+}
+
+func TestDetector_SFTPKnownHosts(t *testing.T) {
+	server, hostKey := sshTestServer(t, nil)
+	host, port := startTCPServer(t, server)
+	otherServer, _ := sshTestServer(t, nil)
+	otherHost, otherPort := startTCPServer(t, otherServer)
+	knownHostsPath := filepath.Join(t.TempDir(), "known_hosts")
+	auth := NewSSHAuthOptions("audit", "secret", "")
+
+	det := NewDetector(WithTimeout(2 * time.Second))
+	if err := det.SetKnownHosts(knownHostsPath, "paranoid"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+
+	// strict：known_hosts 不存在时所有主机都是未知主机，不认证的协议检测只记录主机密钥
+	if err := det.SetKnownHosts(knownHostsPath, KnownHostsStrict); err != nil {
+		t.Fatalf("SetKnownHosts failed: %v", err)
+	}
+	diagnostics, err := det.SFTPCheckWithDiagnostics(host, port)
+	if err != nil {
+		t.Fatalf("Expected unauthenticated check to skip verification, got %v", err)
+	}
+	if diagnostics.HostKeySHA256 != ssh.FingerprintSHA256(hostKey) {
+		t.Errorf("Unexpected host key %s", diagnostics.HostKeySHA256)
+	}
+	var hostKeyErr *HostKeyError
+	if err := det.SFTPCheckWithAuthOptions(host, port, auth); !errors.As(err, &hostKeyErr) || len(hostKeyErr.Known) != 0 {
+		t.Fatalf("Expected unknown host error on authenticated check, got %v", err)
+	}
+	if hostKeyErr.Key.SHA256 != ssh.FingerprintSHA256(hostKey) {
+		t.Errorf("Unexpected host key in error %+v", hostKeyErr.Key)
+	}
+
+	// accept-new：登录时新主机写入 known_hosts，之后 strict 也能通过
+	if err := det.SetKnownHosts(knownHostsPath, KnownHostsAcceptNew); err != nil {
+		t.Fatalf("SetKnownHosts failed: %v", err)
+	}
+	if _, err := det.SFTPCheckWithDiagnostics(host, port); err != nil {
+		t.Fatalf("Unexpected error on unauthenticated check: %v", err)
+	}
+	if _, err := os.Stat(knownHostsPath); !os.IsNotExist(err) {
+		t.Errorf("Unauthenticated check must not write known_hosts, got %v", err)
+	}
+	if _, err := det.SFTPAudit(host, port, auth, false); err != nil {
+		t.Fatalf("Expected new host to be accepted, got %v", err)
+	}
+	data, _ := os.ReadFile(knownHostsPath)
+	if !strings.Contains(string(data), knownhosts.Normalize(net.JoinHostPort(host, port))) {
+		t.Errorf("Host was not written to known_hosts: %q", data)
+	}
+	det.SetKnownHosts(knownHostsPath, KnownHostsStrict)
+	if _, err := det.SFTPAudit(host, port, auth, false); err != nil {
+		t.Errorf("Expected audit of known host to pass strict check, got %v", err)
+	}
+
+	// 密钥不一致时 accept-new 也必须失败
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(otherHost, otherPort))}, hostKey)
+	if err := os.WriteFile(knownHostsPath, []byte(line+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+	det.SetKnownHosts(knownHostsPath, KnownHostsAcceptNew)
+	if _, err := det.SFTPCheckWithDiagnostics(otherHost, otherPort); err != nil {
+		t.Errorf("Unexpected error on unauthenticated check: %v", err)
+	}
+	if _, err := det.SFTPAudit(otherHost, otherPort, auth, false); !errors.As(err, &hostKeyErr) || len(hostKeyErr.Known) != 1 {
+		t.Errorf("Expected host key mismatch on audit, got %v", err)
+	}
+
+	det.SetKnownHosts(knownHostsPath, KnownHostsInsecure)
+	if _, err := det.SFTPAudit(otherHost, otherPort, auth, false); err != nil {
+		t.Errorf("Expected insecure mode to skip verification, got %v", err)
+	}
+}
+
+// TestScanTools_SFTPHostKeyError 测试登录确认和能力审计的主机密钥校验失败写入附加信息
+func TestScanTools_SFTPHostKeyError(t *testing.T) {
+	server, _ := sshTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	})
+	host, port := startTCPServer(t, server)
+	det := NewDetector(WithTimeout(2 * time.Second))
+	if err := det.SetKnownHosts(filepath.Join(t.TempDir(), "known_hosts"), KnownHostsStrict); err != nil {
+		t.Fatalf("SetKnownHosts failed: %v", err)
+	}
+	deliveryInfo := DeliveryInfo{Detector: det, Host: host, Port: port, User: "audit", Password: "secret"}
+
+	for _, audit := range []bool{false, true} {
+		scanTools := NewScanTools(1, 2*time.Second)
+		scanTools.SetSFTPAuthFallback(true)
+		scanTools.SetSFTPAudit(audit, false)
+		metadata, err := scanTools.runCheck(SFTP, deliveryInfo)
+		if err != nil {
+			t.Fatalf("audit=%v: SFTP scan failed: %v", audit, err)
+		}
+		if metadata["sftp_status"] != string(SFTPStatusUnknown) {
+			t.Errorf("audit=%v: Expected auth_required, got %v", audit, metadata)
+		}
+		if !strings.Contains(metadata["sftp_hostkey_error"], "not in known_hosts") {
+			t.Errorf("audit=%v: Expected sftp_hostkey_error, got %v", audit, metadata)
+		}
+	}
+}
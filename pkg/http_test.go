@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/allanpk716/go-protocol-detector/internal/custom_error"
+)
+
+// testFaviconHash 测试 favicon（0-255 重复 3 次）的 mmh3 哈希，base64 编码超过 76 个字符，会包含换行
+const testFaviconHash = "1836528006"
+
+func testFavicon() []byte {
+	favicon := make([]byte, 768)
+	for i := range favicon {
+		favicon[i] = byte(i)
+	}
+	return favicon
+}
+
+func httpTestHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Server", "nginx/1.24.0")
+		w.Write([]byte("<html><head>\n<TITLE lang=\"en\">\n  Router  Admin &amp; Login\n</TITLE></head><body></body></html>"))
+	})
+	mux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testFavicon())
+	})
+	return mux
+}
+
+func httpTestAddr(t *testing.T, server *httptest.Server) (string, string) {
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse server url: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(u.Host)
+	return host, port
+}
+
+func TestDetector_HTTPCheck(t *testing.T) {
+	server := httptest.NewServer(httpTestHandler())
+	defer server.Close()
+	host, port := httpTestAddr(t, server)
+
+	det := NewDetector(WithTimeout(time.Second))
+	info, err := det.HTTPCheck(host, port)
+	if err != nil {
+		t.Fatalf("HTTP check failed: %v", err)
+	}
+	metadata := info.Metadata()
+	if info.Scheme != "http" || metadata["http_status"] != "200" || metadata["http_server"] != "nginx/1.24.0" {
+		t.Errorf("Unexpected HTTP info %+v", info)
+	}
+	if info.Title != "Router Admin & Login" {
+		t.Errorf("Unexpected title %q", info.Title)
+	}
+	if metadata["http_favicon_hash"] != testFaviconHash {
+		t.Errorf("Expected favicon hash %s, got %q", testFaviconHash, metadata["http_favicon_hash"])
+	}
+	if _, ok := metadata["http_location"]; ok {
+		t.Error("Location should only be recorded for redirects")
+	}
+	if _, ok := metadata["http_cert_sha256"]; ok {
+		t.Error("Plain HTTP should not have a certificate")
+	}
+}
+
+func TestDetector_HTTPSCheck(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	host, port := httpTestAddr(t, server)
+
+	det := NewDetector(WithTimeout(time.Second))
+	info, err := det.HTTPCheck(host, port)
+	if err != nil {
+		t.Fatalf("HTTPS check failed: %v", err)
+	}
+	metadata := info.Metadata()
+	if info.Scheme != "https" || info.StatusCode != http.StatusFound || info.Location != "/login" || metadata["http_location"] != "/login" {
+		t.Errorf("Unexpected HTTPS info %+v", info)
+	}
+	if info.Certificate == nil || metadata["http_cert_sha256"] == "" || metadata["http_tls_version"] == "" {
+		t.Errorf("Expected certificate and TLS version, got %+v", metadata)
+	}
+	if _, ok := metadata["http_favicon_hash"]; ok {
+		t.Error("Missing favicon should not produce a hash")
+	}
+}
+
+func TestDetector_HTTPCheckNotHTTP(t *testing.T) {
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	})))
+	if _, err := det.HTTPCheck("ssh.test", "22"); err != custom_error.ErrHTTPNotFound {
+		t.Errorf("Expected ErrHTTPNotFound, got %v", err)
+	}
+}
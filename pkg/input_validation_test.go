@@ -94,7 +94,7 @@ func TestPortValidation(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ports, err := scan.parsePort(tc.port)
+			ports, err := scan.parsePort(tc.port, Common)
 
 			if tc.shouldError {
 				if err == nil {
@@ -201,7 +201,7 @@ func TestInputInfoValidation(t *testing.T) {
 			}
 
 			// 测试parsePort
-			_, err = scan.parsePort(tc.inputInfo.Port)
+			_, err = scan.parsePort(tc.inputInfo.Port, Common)
 			if tc.inputInfo.Port == "" {
 				if err == nil {
 					t.Error("Expected error for empty port, but got none")
@@ -167,7 +167,7 @@ func TestPortRangePerformance(t *testing.T) {
 	t.Run("LargePortRange", func(t *testing.T) {
 		start := time.Now()
 
-		ports, err := scan.parsePort("1000-2000")
+		ports, err := scan.parsePort("1000-2000", Common)
 		duration := time.Since(start)
 
 		if err != nil {
@@ -191,7 +191,7 @@ func TestPortRangePerformance(t *testing.T) {
 	t.Run("MultiplePortRanges", func(t *testing.T) {
 		start := time.Now()
 
-		ports, err := scan.parsePort("80,443,8080-8090,9000-9100")
+		ports, err := scan.parsePort("80,443,8080-8090,9000-9100", Common)
 		duration := time.Since(start)
 
 		if err != nil {
@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/allanpk716/go-protocol-detector/internal/errors"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/ftp"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/http"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/rdp"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/sftp"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/smtp"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/ssh"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/telnet"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/tls"
+	"github.com/allanpk716/go-protocol-detector/internal/feature/vnc"
+)
+
+const (
+	PortProfileDefault      = "default"       // 当前协议的常用端口
+	PortProfileTop100       = "top100"        // 最常见的 100 个 TCP 端口
+	PortProfileRemoteAccess = "remote-access" // 远程管理相关端口
+)
+
+// top100Ports 最常见的 100 个 TCP 端口（参考 nmap-services 的统计排序）
+var top100Ports = []int{
+	7, 9, 13, 21, 22, 23, 25, 26, 37, 53, 79, 80, 81, 88, 106, 110, 111, 113, 119, 135,
+	139, 143, 144, 179, 199, 389, 427, 443, 444, 445, 465, 513, 514, 515, 543, 544, 548, 554, 587, 631,
+	646, 873, 990, 993, 995, 1025, 1026, 1027, 1028, 1029, 1110, 1433, 1720, 1723, 1755, 1900, 2000, 2001, 2049, 2121,
+	2717, 3000, 3128, 3306, 3389, 3986, 4899, 5000, 5009, 5051, 5060, 5101, 5190, 5357, 5432, 5631, 5666, 5800, 5900, 6000,
+	6001, 6646, 7070, 8000, 8008, 8009, 8080, 8081, 8443, 8888, 9100, 9999, 10000, 32768, 49152, 49153, 49154, 49155, 49156, 49157,
+}
+
+// remoteAccessPorts 远程管理相关端口：SSH、Telnet、RDP、VNC、WinRM
+var remoteAccessPorts = []int{22, 23, 2323, 3389, 5800, 5900, 5901, 5902, 5903, 5904, 5905, 5906, 5907, 5908, 5909, 5910, 5985, 5986}
+
+// GetProtocolDefaultPorts 返回协议的常用端口，Common 等没有固定端口的协议返回 nil
+func GetProtocolDefaultPorts(protocolType ProtocolType) []int {
+	switch protocolType {
+	case RDP:
+		return rdp.DefaultPorts()
+	case SSH:
+		return ssh.DefaultPorts()
+	case FTP:
+		return ftp.DefaultPorts()
+	case FTPS:
+		return ftp.FTPSDefaultPorts()
+	case HTTP:
+		return http.DefaultPorts()
+	case TLS:
+		return tls.DefaultPorts()
+	case SMTP:
+		return smtp.DefaultPorts()
+	case SFTP:
+		return sftp.DefaultPorts()
+	case Telnet:
+		return telnet.DefaultPorts()
+	case VNC:
+		return vnc.DefaultPorts()
+	default:
+		return nil
+	}
+}
+
+// resolvePortProfile 解析命名端口集合，不是命名端口集合时 ok 返回 false
+func resolvePortProfile(name string, protocolType ProtocolType) (ports []int, ok bool, err error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case PortProfileDefault:
+		ports = GetProtocolDefaultPorts(protocolType)
+		if len(ports) == 0 {
+			return nil, true, errors.NewValidationError(
+				fmt.Sprintf("protocol %s has no default ports, please specify ports explicitly", protocolType.String()),
+				nil,
+			)
+		}
+		return ports, true, nil
+	case PortProfileTop100:
+		ports = make([]int, len(top100Ports))
+		copy(ports, top100Ports)
+		return ports, true, nil
+	case PortProfileRemoteAccess:
+		ports = make([]int, len(remoteAccessPorts))
+		copy(ports, remoteAccessPorts)
+		return ports, true, nil
+	default:
+		return nil, false, nil
+	}
+}
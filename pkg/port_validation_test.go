@@ -96,7 +96,7 @@ func TestParsePort_Validation(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ports, err := scan.parsePort(tc.input)
+			ports, err := scan.parsePort(tc.input, Common)
 
 			if tc.expectError {
 				if err == nil {
@@ -118,7 +118,7 @@ func TestParsePort_LargeRange(t *testing.T) {
 	scan := ScanTools{}
 
 	// 测试接近限制的大端口范围
-	ports, err := scan.parsePort("8000-8999") // 1000 ports
+	ports, err := scan.parsePort("8000-8999", Common) // 1000 ports
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -127,7 +127,7 @@ func TestParsePort_LargeRange(t *testing.T) {
 	}
 
 	// 测试刚好在限制内的端口范围
-	ports, err = scan.parsePort("1-10000") // 10000 ports - should work
+	ports, err = scan.parsePort("1-10000", Common) // 10000 ports - should work
 	if err != nil {
 		t.Fatalf("Unexpected error for 10000 ports: %v", err)
 	}
@@ -136,8 +136,49 @@ func TestParsePort_LargeRange(t *testing.T) {
 	}
 
 	// 测试超过限制的端口范围
-	_, err = scan.parsePort("1-10001") // 10001 ports - should fail
+	_, err = scan.parsePort("1-10001", Common) // 10001 ports - should fail
 	if err == nil {
 		t.Error("Expected error for port range exceeding 10000 ports")
 	}
-}
\ No newline at end of file
+}
+
+// TestParsePort_Profiles 测试 top100、default、remote-access 等命名端口集合的展开
+func TestParsePort_Profiles(t *testing.T) {
+	scan := ScanTools{}
+
+	testCases := []struct {
+		name         string
+		input        string
+		protocolType ProtocolType
+		expectError  bool
+		expectedLen  int
+	}{
+		{"top100", "top100", Common, false, 100},
+		{"top100 upper case", "TOP100", Common, false, 100},
+		{"rdp default", "default", RDP, false, 1},
+		{"telnet default", "default", Telnet, false, 2},
+		{"vnc default", "default", VNC, false, 11},
+		{"common has no default", "default", Common, true, 0},
+		{"remote access", "remote-access", Common, false, 18},
+		{"profile mixed with ports", "default,8022,9000-9001", SSH, false, 4},
+		{"unknown profile", "top1000", Common, true, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ports, err := scan.parsePort(tc.input, tc.protocolType)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected error for input %s, but got none", tc.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error for input %s: %v", tc.input, err)
+			}
+			if len(ports) != tc.expectedLen {
+				t.Errorf("Expected %d ports for input %s, got %d", tc.expectedLen, tc.input, len(ports))
+			}
+		})
+	}
+}
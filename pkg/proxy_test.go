@@ -0,0 +1,148 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// startBannerServer 启动一个连接后立即发送 banner 的 TCP 服务
+func startBannerServer(t *testing.T, banner string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				conn.Write([]byte(banner))
+				io.Copy(io.Discard, conn)
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// pipeConn 双向转发两个连接的数据
+func pipeConn(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+// startSOCKS5Server 启动一个只支持无认证 CONNECT 的最小 SOCKS5 代理
+func startSOCKS5Server(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				greeting := make([]byte, 2)
+				if _, err := io.ReadFull(conn, greeting); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, make([]byte, greeting[1])); err != nil {
+					return
+				}
+				conn.Write([]byte{0x05, 0x00})
+
+				header := make([]byte, 4)
+				if _, err := io.ReadFull(conn, header); err != nil || header[3] != 0x01 {
+					return
+				}
+				addr := make([]byte, 6)
+				if _, err := io.ReadFull(conn, addr); err != nil {
+					return
+				}
+				target := net.JoinHostPort(net.IP(addr[:4]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(addr[4:]))))
+				targetConn, err := net.Dial("tcp", target)
+				if err != nil {
+					conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+				pipeConn(conn, targetConn)
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// startHTTPConnectServer 启动一个最小的 HTTP CONNECT 代理
+func startHTTPConnectServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				targetConn, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+					return
+				}
+				conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+				pipeConn(conn, targetConn)
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestDetector_SetProxy(t *testing.T) {
+	sshAddr := startBannerServer(t, "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n")
+	sshHost, sshPort, _ := net.SplitHostPort(sshAddr)
+
+	proxies := map[string]string{
+		"socks5": "socks5://" + startSOCKS5Server(t),
+		"http":   "http://" + startHTTPConnectServer(t),
+	}
+	for name, proxyURL := range proxies {
+		t.Run(name, func(t *testing.T) {
+			det := NewDetector(WithTimeout(timeOut))
+			if err := det.SetProxy(proxyURL); err != nil {
+				t.Fatalf("SetProxy failed: %v", err)
+			}
+			if err := det.SSHCheck(sshHost, sshPort); err != nil {
+				t.Errorf("SSH check through %s proxy failed: %v", name, err)
+			}
+		})
+	}
+
+	det := NewDetector(WithTimeout(timeOut))
+	if err := det.SetProxy("ftp://127.0.0.1:21"); err == nil {
+		t.Error("Expected error for unsupported proxy scheme")
+	}
+}
@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allanpk716/go-protocol-detector/internal/custom_error"
+)
+
+// smtpTestServer 模拟 SMTP 服务端
+// implicitTLS 不为 nil 时连接建立后直接进行 TLS 握手；starttls 不为 nil 时声明并支持 STARTTLS，升级后额外声明 AUTH PLAIN LOGIN
+// extensions 为 nil 时不支持 EHLO，只支持 HELO
+func smtpTestServer(implicitTLS, starttls *tls.Config, extensions []string) func(net.Conn) {
+	return func(conn net.Conn) {
+		if implicitTLS != nil {
+			tlsConn := tls.Server(conn, implicitTLS)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+		}
+		conn.Write([]byte("220-mail.example.com ESMTP Postfix\r\n220 No UCE\r\n"))
+		reader := bufio.NewReader(conn)
+		upgraded := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			verb := strings.ToUpper(strings.Fields(line + " x")[0])
+			switch {
+			case verb == "EHLO" && extensions != nil:
+				lines := []string{"mail.example.com"}
+				for _, ext := range extensions {
+					// 升级后只声明 AUTH PLAIN LOGIN
+					if !upgraded || !strings.HasPrefix(ext, "AUTH") {
+						lines = append(lines, ext)
+					}
+				}
+				if starttls != nil && !upgraded {
+					lines = append(lines, "STARTTLS")
+				}
+				if upgraded {
+					lines = append(lines, "AUTH PLAIN LOGIN")
+				}
+				for i, ext := range lines {
+					separator := "-"
+					if i == len(lines)-1 {
+						separator = " "
+					}
+					conn.Write([]byte("250" + separator + ext + "\r\n"))
+				}
+			case verb == "HELO":
+				conn.Write([]byte("250 mail.example.com\r\n"))
+			case verb == "STARTTLS" && starttls != nil && !upgraded:
+				conn.Write([]byte("220 2.0.0 Ready to start TLS\r\n"))
+				tlsConn := tls.Server(conn, starttls)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				conn, reader, upgraded = tlsConn, bufio.NewReader(tlsConn), true
+			case verb == "QUIT":
+				conn.Write([]byte("221 2.0.0 Bye\r\n"))
+				return
+			default:
+				conn.Write([]byte("502 5.5.2 Error: command not recognized\r\n"))
+			}
+		}
+	}
+}
+
+func TestDetector_SMTPCheck(t *testing.T) {
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{generateTestCertificate(t, "mail.example.com")}}
+	extensions := []string{"PIPELINING", "SIZE 10240000", "AUTH=PLAIN LOGIN", "AUTH PLAIN LOGIN CRAM-MD5", "8BITMIME"}
+	host, port := startTCPServer(t, smtpTestServer(nil, tlsConfig, extensions))
+
+	det := NewDetector(WithTimeout(time.Second))
+	info, err := det.SMTPCheck(host, port, false)
+	if err != nil {
+		t.Fatalf("SMTP check failed: %v", err)
+	}
+	metadata := info.Metadata()
+	if metadata["smtp_banner"] != "mail.example.com ESMTP Postfix | No UCE" || metadata["smtp_ehlo"] != "mail.example.com" || metadata["smtp_code"] != "220" {
+		t.Errorf("Unexpected greeting %+v", metadata)
+	}
+	if !info.StartTLS || !info.Pipelining || info.Size != "10240000" || len(info.Extensions) != 6 {
+		t.Errorf("Unexpected extensions %+v", info)
+	}
+	if metadata["smtp_auth"] != "PLAIN,LOGIN,CRAM-MD5" {
+		t.Errorf("Unexpected auth mechanisms %q", metadata["smtp_auth"])
+	}
+	if !info.PlaintextAuth || metadata["smtp_plaintext_auth"] != "true" || info.ImplicitTLS {
+		t.Errorf("Expected plaintext AUTH PLAIN/LOGIN to be flagged, got %+v", metadata)
+	}
+	if _, ok := metadata["smtp_starttls_ok"]; ok || info.TLS != nil {
+		t.Error("STARTTLS should be opt-in")
+	}
+
+	info, err = det.SMTPCheck(host, port, true)
+	if err != nil {
+		t.Fatalf("SMTP STARTTLS check failed: %v", err)
+	}
+	metadata = info.Metadata()
+	if metadata["smtp_starttls_ok"] != "true" || metadata["smtp_tls_version"] != "TLS 1.3" || metadata["smtp_tls_cert_subject_cn"] != "mail.example.com" {
+		t.Errorf("Unexpected STARTTLS result %+v", metadata)
+	}
+	if metadata["smtp_tls_auth"] != "PLAIN,LOGIN" {
+		t.Errorf("Unexpected auth mechanisms after STARTTLS %q", metadata["smtp_tls_auth"])
+	}
+}
+
+func TestDetector_SMTPCheckImplicitTLS(t *testing.T) {
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{generateTestCertificate(t, "smtps.example.com")}}
+	host, port := startTCPServer(t, smtpTestServer(tlsConfig, nil, []string{"AUTH PLAIN LOGIN"}))
+
+	// 不是 465 端口，明文连接读取欢迎信息超时后再尝试 implicit TLS
+	det := NewDetector(WithTimeout(time.Second))
+	info, err := det.SMTPCheck(host, port, true)
+	if err != nil {
+		t.Fatalf("SMTPS check failed: %v", err)
+	}
+	metadata := info.Metadata()
+	if !info.ImplicitTLS || metadata["smtp_tls_cert_subject_cn"] != "smtps.example.com" {
+		t.Errorf("Expected implicit TLS, got %+v", metadata)
+	}
+	// AUTH PLAIN 在 TLS 连接上是安全的
+	if info.PlaintextAuth || info.StartTLSTested || metadata["smtp_auth"] != "PLAIN,LOGIN" {
+		t.Errorf("Unexpected auth result %+v", metadata)
+	}
+
+	// 465 端口先尝试 implicit TLS，只需要一次连接；其它端口先使用明文连接
+	for _, port := range []string{"465", "587"} {
+		dials := 0
+		server := smtpTestServer(tlsConfig, nil, []string{"AUTH PLAIN LOGIN"})
+		if port == "587" {
+			server = smtpTestServer(nil, nil, []string{"AUTH PLAIN LOGIN"})
+		}
+		det := NewDetector(WithTimeout(time.Second), WithDialContext(func(ctx context.Context, network, address string) (net.Conn, error) {
+			dials++
+			return pipeDialer(server)(ctx, network, address)
+		}))
+		info, err := det.SMTPCheck("mail.test", port, false)
+		if err != nil || info.ImplicitTLS != (port == "465") || dials != 1 {
+			t.Errorf("port %s: Expected a single connection, got %d (%+v, %v)", port, dials, info, err)
+		}
+	}
+}
+
+func TestDetector_SMTPCheckRefused(t *testing.T) {
+	host, port := startTCPServer(t, func(conn net.Conn) {
+		conn.Write([]byte("554 5.7.1 No SMTP service here\r\n"))
+		reader := bufio.NewReader(conn)
+		if line, err := reader.ReadString('\n'); err == nil && strings.HasPrefix(line, "QUIT") {
+			conn.Write([]byte("221 2.0.0 Bye\r\n"))
+		}
+	})
+	det := NewDetector(WithTimeout(time.Second))
+	info, err := det.SMTPCheck(host, port, true)
+	if err != nil {
+		t.Fatalf("Expected 554 greeting to be SMTP, got %v", err)
+	}
+	metadata := info.Metadata()
+	if info.GreetingCode != 554 || metadata["smtp_code"] != "554" || metadata["smtp_banner"] != "5.7.1 No SMTP service here" {
+		t.Errorf("Unexpected greeting %+v", metadata)
+	}
+	if info.ESMTP || info.ImplicitTLS || info.StartTLSTested {
+		t.Errorf("Expected no commands after a 554 greeting, got %+v", info)
+	}
+}
+
+func TestDetector_SMTPCheckNotSMTP(t *testing.T) {
+	// 只支持 HELO 的老旧服务端仍然是 SMTP
+	host, port := startTCPServer(t, smtpTestServer(nil, nil, nil))
+	det := NewDetector(WithTimeout(time.Second))
+	info, err := det.SMTPCheck(host, port, false)
+	if err != nil || info.ESMTP || info.Metadata()["smtp_esmtp"] != "false" {
+		t.Errorf("Expected SMTP without ESMTP, got %+v (%v)", info, err)
+	}
+
+	servers := map[string]func(net.Conn){
+		"ftp": ftpTestServer("220 (vsFTPd 3.0.5)\r\n", false, nil),
+		"ssh": func(conn net.Conn) {
+			go io.Copy(io.Discard, conn)
+			conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1\r\n"))
+			time.Sleep(100 * time.Millisecond)
+		},
+	}
+	for name, server := range servers {
+		host, port := startTCPServer(t, server)
+		if _, err := det.SMTPCheck(host, port, false); err != custom_error.ErrSMTPNotFound {
+			t.Errorf("Expected ErrSMTPNotFound for %s, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package pkg
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allanpk716/go-protocol-detector/internal/custom_error"
+)
+
+// sniTestServer 按客户端发送的 SNI 返回不同的证书，requireSNI 为 true 时拒绝没有 SNI 的握手
+func sniTestServer(t *testing.T, requireSNI bool) func(net.Conn) {
+	defaultCert := generateTestCertificate(t, "default.test")
+	return func(conn net.Conn) {
+		config := &tls.Config{
+			GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				if hello.ServerName == "" {
+					if requireSNI {
+						return nil, errors.New("sni required")
+					}
+					return &defaultCert, nil
+				}
+				cert := generateTestCertificate(t, hello.ServerName)
+				return &cert, nil
+			},
+		}
+		tlsConn := tls.Server(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		io.Copy(io.Discard, tlsConn)
+	}
+}
+
+func TestDetector_TLSCheck(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	host, port := httpTestAddr(t, server)
+
+	det := NewDetector(WithTimeout(time.Second))
+	info, err := det.TLSCheck(host, port, "")
+	if err != nil {
+		t.Fatalf("TLS check failed: %v", err)
+	}
+	metadata := info.Metadata()
+	if metadata["tls_version"] != "TLS 1.3" || metadata["tls_cipher"] == "" || metadata["tls_alpn"] != "h2" {
+		t.Errorf("Unexpected handshake %+v", metadata)
+	}
+	if metadata["tls_chain_length"] != "1" || metadata["tls_cert_key_type"] != "RSA" || metadata["tls_cert_key_size"] == "0" {
+		t.Errorf("Unexpected certificate %+v", metadata)
+	}
+	if !strings.Contains(metadata["tls_cert_sans"], "example.com") || !strings.Contains(metadata["tls_cert_sans"], "127.0.0.1") {
+		t.Errorf("Unexpected SANs %q", metadata["tls_cert_sans"])
+	}
+	// 目标是 IP，不比较 SNI
+	if _, ok := metadata["tls_sni_required"]; ok || metadata["tls_sni"] != "" {
+		t.Errorf("SNI should not be tested for IP targets: %+v", metadata)
+	}
+}
+
+func TestDetector_TLSCheckSNI(t *testing.T) {
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(sniTestServer(t, false))))
+	info, err := det.TLSCheck("www.test", "443", "")
+	if err != nil {
+		t.Fatalf("TLS check failed: %v", err)
+	}
+	metadata := info.Metadata()
+	if metadata["tls_sni"] != "www.test" || metadata["tls_cert_subject_cn"] != "www.test" {
+		t.Errorf("Expected certificate for the SNI, got %+v", metadata)
+	}
+	if metadata["tls_sni_required"] != "false" || metadata["tls_sni_cert_differs"] != "true" || metadata["tls_sni_rejected"] != "" {
+		t.Errorf("Expected different certificate per SNI, got %+v", metadata)
+	}
+	if metadata["tls_cert_key_type"] != "ECDSA" || metadata["tls_cert_key_size"] != "256" {
+		t.Errorf("Unexpected key %s %s", metadata["tls_cert_key_type"], metadata["tls_cert_key_size"])
+	}
+
+	// 扫描 IP 时指定 SNI
+	info, err = det.TLSCheck("127.0.0.1", "443", "vhost.test")
+	if err != nil || !info.SNITested || info.Leaf().SubjectCN != "vhost.test" {
+		t.Errorf("Expected explicit SNI to be used, got %+v (%v)", info, err)
+	}
+
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(sniTestServer(t, true))))
+	info, err = det.TLSCheck("www.test", "443", "")
+	if err != nil {
+		t.Fatalf("TLS check failed: %v", err)
+	}
+	if !info.SNIRequired || info.Metadata()["tls_sni_required"] != "true" {
+		t.Errorf("Expected SNI to be required, got %+v", info)
+	}
+	if _, err := det.TLSCheck("127.0.0.1", "443", ""); err != custom_error.ErrTLSNotFound {
+		t.Errorf("Expected ErrTLSNotFound without SNI, got %v", err)
+	}
+
+	// 服务端拒绝这个 SNI，不发送 SNI 时握手成功
+	defaultCert := generateTestCertificate(t, "default.test")
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		tlsConn := tls.Server(conn, &tls.Config{
+			GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				if hello.ServerName != "" {
+					return nil, errors.New("unknown server name")
+				}
+				return &defaultCert, nil
+			},
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		io.Copy(io.Discard, tlsConn)
+	})))
+	info, err = det.TLSCheck("www.test", "443", "")
+	if err != nil {
+		t.Fatalf("TLS check failed: %v", err)
+	}
+	metadata = info.Metadata()
+	if !info.SNITested || metadata["tls_sni_rejected"] != "true" || metadata["tls_cert_subject_cn"] != "default.test" {
+		t.Errorf("Expected SNI to be rejected, got %+v", metadata)
+	}
+}
+
+// TestDetector_TLSCheckChain 测试叶子证书之后的证书按位置写入有效期和密钥等附加信息
+func TestDetector_TLSCheckChain(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	caNotAfter := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              caNotAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.test"},
+		DNSNames:     []string{"www.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caTemplate, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{leafDER, caDER}, PrivateKey: leafKey}
+
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		io.Copy(io.Discard, tlsConn)
+	})))
+	info, err := det.TLSCheck("127.0.0.1", "443", "")
+	if err != nil {
+		t.Fatalf("TLS check failed: %v", err)
+	}
+	metadata := info.Metadata()
+	if metadata["tls_chain_length"] != "2" || metadata["tls_cert_subject_cn"] != "www.test" || metadata["tls_cert_issuer"] != "CN=Test Intermediate CA" {
+		t.Errorf("Unexpected leaf certificate %+v", metadata)
+	}
+	if metadata["tls_chain_1_cert_subject_cn"] != "Test Intermediate CA" || metadata["tls_chain_1_cert_self_signed"] != "true" {
+		t.Errorf("Unexpected intermediate certificate %+v", metadata)
+	}
+	if metadata["tls_chain_1_cert_not_after"] != caNotAfter.UTC().Format(time.RFC3339) || metadata["tls_chain_1_cert_expired"] != "false" {
+		t.Errorf("Unexpected intermediate validity %+v", metadata)
+	}
+	if metadata["tls_chain_1_cert_key_type"] != "ECDSA" || metadata["tls_chain_1_cert_key_size"] != "384" {
+		t.Errorf("Unexpected intermediate key %s %s", metadata["tls_chain_1_cert_key_type"], metadata["tls_chain_1_cert_key_size"])
+	}
+	if _, ok := metadata["tls_chain_0_cert_subject"]; ok {
+		t.Error("Leaf certificate should only be recorded once")
+	}
+}
+
+func TestDetector_TLSCheckNotTLS(t *testing.T) {
+	det := NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	})))
+	if _, err := det.TLSCheck("ssh.test", "22", ""); err != custom_error.ErrTLSNotFound {
+		t.Errorf("Expected ErrTLSNotFound, got %v", err)
+	}
+}
+
+func TestDetector_TLSEnumerate(t *testing.T) {
+	cert := generateTestCertificate(t, "legacy.test")
+	legacyServer := func(conn net.Conn) {
+		tls.Server(conn, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS10,
+			MaxVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+				tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+			},
+		}).Handshake()
+	}
+	host, port := startTCPServer(t, legacyServer)
+
+	waits := 0
+	det := NewDetector(WithTimeout(time.Second), WithRateLimit(func(ctx context.Context) error {
+		waits++
+		return nil
+	}))
+	info, err := det.TLSEnumerate(context.Background(), host, port, "")
+	if err != nil {
+		t.Fatalf("TLS enumeration failed: %v", err)
+	}
+	metadata := info.Metadata()
+	if metadata["tls_versions"] != "TLS 1.0,TLS 1.1,TLS 1.2" || metadata["tls_weak_versions"] != "TLS 1.0,TLS 1.1" {
+		t.Errorf("Unexpected versions %+v", metadata)
+	}
+	expected12 := "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,TLS_ECDHE_ECDSA_WITH_RC4_128_SHA"
+	if metadata["tls_ciphers_tls1_2"] != expected12 {
+		t.Errorf("Unexpected TLS 1.2 ciphers %q", metadata["tls_ciphers_tls1_2"])
+	}
+	// GCM 只能用于 TLS 1.2
+	if metadata["tls_ciphers_tls1_0"] != "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,TLS_ECDHE_ECDSA_WITH_RC4_128_SHA" {
+		t.Errorf("Unexpected TLS 1.0 ciphers %q", metadata["tls_ciphers_tls1_0"])
+	}
+	if metadata["tls_weak_ciphers"] != "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA" || info.Grade != "weak" {
+		t.Errorf("Expected weak grade because of RC4, got %+v", metadata)
+	}
+	if _, ok := metadata["tls_ciphers_ssl3_0"]; ok {
+		t.Error("SSL 3.0 should be rejected")
+	}
+	// 每个版本：接受的套件各一次握手，加上最后一次被拒绝的握手（SSL 3.0、TLS 1.3 只有被拒绝的一次）
+	if waits != 1+3+3+4+1 {
+		t.Errorf("Expected every handshake to wait for the rate limiter, got %d waits", waits)
+	}
+
+	modernServer := func(conn net.Conn) {
+		tls.Server(conn, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305},
+		}).Handshake()
+	}
+	host, port = startTCPServer(t, modernServer)
+	det = NewDetector(WithTimeout(time.Second))
+	info, err = det.TLSEnumerate(context.Background(), host, port, "")
+	if err != nil {
+		t.Fatalf("TLS enumeration failed: %v", err)
+	}
+	metadata = info.Metadata()
+	if metadata["tls_versions"] != "TLS 1.2,TLS 1.3" || info.Grade != "strong" || metadata["tls_weak_ciphers"] != "" {
+		t.Errorf("Unexpected enumeration %+v", metadata)
+	}
+	if len(info.Versions) != 2 || len(info.Versions[0].Ciphers) != 2 || len(info.Versions[1].Ciphers) != 3 {
+		t.Errorf("Unexpected ciphers %+v", info.Versions)
+	}
+
+	// 取消的上下文结束等待速率限制的枚举
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	det = NewDetector(WithTimeout(time.Second), WithRateLimit(func(ctx context.Context) error {
+		return ctx.Err()
+	}))
+	if _, err := det.TLSEnumerate(ctx, host, port, ""); err != custom_error.ErrTLSNotFound {
+		t.Errorf("Expected enumeration to stop with the context, got %v", err)
+	}
+
+	// 某次建立连接失败只结束该版本的枚举
+	host, port = startTCPServer(t, legacyServer)
+	dials := 0
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dials++
+		if dials == 3 {
+			// SSL 3.0 一次，TLS 1.0 的第二次握手
+			return nil, errors.New("connection refused")
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}))
+	info, err = det.TLSEnumerate(context.Background(), host, port, "")
+	if err != nil {
+		t.Fatalf("TLS enumeration failed: %v", err)
+	}
+	metadata = info.Metadata()
+	if metadata["tls_versions"] != "TLS 1.0,TLS 1.1,TLS 1.2" || metadata["tls_ciphers_tls1_0"] != "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA" {
+		t.Errorf("Expected only TLS 1.0 to be cut short, got %+v", metadata)
+	}
+	if metadata["tls_ciphers_tls1_2"] != expected12 {
+		t.Errorf("Unexpected TLS 1.2 ciphers %q", metadata["tls_ciphers_tls1_2"])
+	}
+
+	det = NewDetector(WithTimeout(time.Second), WithDialContext(pipeDialer(func(conn net.Conn) {
+		go io.Copy(io.Discard, conn)
+		conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	})))
+	if _, err := det.TLSEnumerate(context.Background(), "ssh.test", "22", ""); err != custom_error.ErrTLSNotFound {
+		t.Errorf("Expected ErrTLSNotFound, got %v", err)
+	}
+}